@@ -0,0 +1,130 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// cmdAggregate handles SUM, AVG, MIN, MAX and COUNT, either over a list,
+// "SUM key|path", or over a field of the objects whose keys match a pattern,
+// "SUM field FROM pattern [WHERE cond [AND cond ...]] [GROUP BY field]".
+// "COUNT * FROM ..." counts the objects themselves. Grouped results are
+// [group, value] pairs sorted by group; objects without the GROUP BY field
+// fall in the "null" group.
+func cmdAggregate(e *Executor, args []string) (interface{}, error) {
+	fn, err := core.ParseAggFunc(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 2 {
+		return e.aggregateList(fn, args[1])
+	}
+
+	if len(args) < 4 || !strings.EqualFold(args[2], "FROM") {
+		return nil, fmt.Errorf("%w: expected '%s key' or '%s field FROM pattern'", core.ErrParse, fn, fn)
+	}
+	var field []core.PathSegment
+	if args[1] != "*" {
+		if field, err = core.ParseFieldPath(args[1]); err != nil {
+			return nil, err
+		}
+	} else if fn != core.AggCount {
+		return nil, fmt.Errorf("%w: only COUNT takes '*'", core.ErrParse)
+	}
+
+	q := &selectQuery{pattern: args[3], limit: -1}
+	rest := args[4:]
+	if q.where, rest, err = parseWhere(rest); err != nil {
+		return nil, err
+	}
+	var groupBy []core.PathSegment
+	if len(rest) == 3 && strings.EqualFold(rest[0], "GROUP") && strings.EqualFold(rest[1], "BY") {
+		if groupBy, err = core.ParseFieldPath(rest[2]); err != nil {
+			return nil, err
+		}
+		rest = rest[3:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: unexpected '%s' in %s", core.ErrParse, rest[0], fn)
+	}
+
+	groups := make(map[string]*core.Aggregator)
+	for _, row := range q.rows(e.tb) {
+		group := ""
+		if groupBy != nil {
+			group = "null"
+			if v, ok := core.FieldValue(row.obj, groupBy); ok {
+				group = core.FormatValue(v)
+			}
+		}
+		agg, ok := groups[group]
+		if !ok {
+			agg = core.NewAggregator(fn, e.strictAggregates)
+			groups[group] = agg
+		}
+
+		var v interface{} = row.obj
+		if field != nil {
+			if v, ok = core.FieldValue(row.obj, field); !ok {
+				continue
+			}
+		}
+		if err := agg.Add(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", row.key, err)
+		}
+	}
+
+	if groupBy == nil {
+		agg, ok := groups[""]
+		if !ok {
+			agg = core.NewAggregator(fn, e.strictAggregates)
+		}
+		return aggregateReply(agg.Result()), nil
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reply := make([]interface{}, len(names))
+	for i, name := range names {
+		reply[i] = []interface{}{name, aggregateReply(groups[name].Result())}
+	}
+	return reply, nil
+}
+
+// aggregateList folds the elements of a list; a missing key is an empty
+// list.
+func (e *Executor) aggregateList(fn core.AggFunc, arg string) (interface{}, error) {
+	val, exists, err := e.valueAt(arg)
+	if err != nil {
+		return nil, err
+	}
+	agg := core.NewAggregator(fn, e.strictAggregates)
+	if exists {
+		list, err := asList(arg, val)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Data {
+			if err := agg.Add(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return aggregateReply(agg.Result()), nil
+}
+
+// aggregateReply sends INT results as integers and the rest as text, the
+// way PRINT shows them.
+func aggregateReply(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, int:
+		return val
+	default:
+		return core.FormatValue(val)
+	}
+}
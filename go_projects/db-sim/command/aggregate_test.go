@@ -0,0 +1,62 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func TestAggregate_ListsAndObjects(t *testing.T) {
+	s := selectFixture(t)
+	for _, v := range []string{"INT 4", "FLOAT 1.5", "STRING x", "INT 2"} {
+		mustRun(t, s, "PUSH nums "+v)
+	}
+	mustRun(t, s, "OBJECT user:5 3 name STRING Eve age STRING unknown city STRING Paris")
+	for _, v := range []string{"0.1", "0.1", "0.1"} {
+		mustRun(t, s, "PUSH prices DECIMAL "+v)
+	}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"SUM nums", "7.5"},
+		{"AVG nums", "2.5"},
+		{"MIN nums", "1.5"},
+		{"MAX nums", "4"},
+		{"COUNT nums", "4"},
+		{"SUM prices", "0.3"},
+		{"AVG prices", "0.1"},
+		{"SUM missing", "0"},
+		{"AVG missing", "<nil>"},
+		{"SUM age FROM user:*", "65.5"},
+		{"SUM age FROM user:* WHERE age < 20", "35.5"},
+		{"COUNT * FROM user:*", "5"},
+		{"COUNT age FROM user:*", "4"},
+		{"MAX age FROM *", "99"},
+		{"AVG age FROM user:* GROUP BY city", "[[Paris 17] [Rome 24.25] [null <nil>]]"},
+		{"COUNT * FROM user:* GROUP BY city", "[[Paris 2] [Rome 2] [null 1]]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+
+	if _, err := run(t, s, "SUM user:1"); !errors.Is(err, core.ErrWrongKind) {
+		t.Fatalf("SUM of an object: expected ErrWrongKind, got %v", err)
+	}
+	if _, err := run(t, s, "SUM * FROM user:*"); !errors.Is(err, core.ErrParse) {
+		t.Fatalf("SUM *: expected ErrParse, got %v", err)
+	}
+
+	s.e.SetStrictAggregates(true)
+	if _, err := run(t, s, "SUM nums"); !errors.Is(err, core.ErrWrongKind) {
+		t.Fatalf("strict SUM: expected ErrWrongKind, got %v", err)
+	}
+	if _, err := run(t, s, "AVG age FROM user:*"); !errors.Is(err, core.ErrWrongKind) {
+		t.Fatalf("strict AVG: expected ErrWrongKind, got %v", err)
+	}
+}
@@ -0,0 +1,651 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/persist"
+	"github.com/dim4d/DbSim/storage"
+)
+
+type Status string
+
+const OK Status = "OK"
+
+type spec struct {
+	arity   int
+	write   bool
+	noMulti bool
+	// exclusive commands see the whole store at once and run with every
+	// other command paused.
+	exclusive bool
+	keys      func(args []string) []string
+	// absolute rewrites relative times in args to absolute ones before the
+	// command runs, so the logged form replays to the same deadline.
+	absolute func(e *Executor, args []string) ([]string, error)
+	// shrinks marks write commands that never make the store bigger; they
+	// still run when it is over its memory limit.
+	shrinks bool
+	// logAs gives the commands to log for a write that cannot be replayed
+	// from its own arguments; it runs right after the write.
+	logAs func(e *Executor, args []string) ([][]string, error)
+	run   func(e *Executor, args []string) (interface{}, error)
+}
+
+var table = map[string]spec{
+	"SET":           {arity: 4, write: true, keys: pathKey, absolute: absSet, run: cmdSet},
+	"OBJECT":        {arity: 3, write: true, keys: firstKey, run: cmdObject},
+	"PUSH":          {arity: 4, write: true, keys: pathKey, run: cmdPush},
+	"INCR":          {arity: 2, write: true, keys: pathKey, run: cmdIncr},
+	"INCRBY":        {arity: 3, write: true, keys: pathKey, run: cmdIncr},
+	"INCRBYFLOAT":   {arity: 3, write: true, keys: pathKey, run: cmdIncr},
+	"APPEND":        {arity: 3, write: true, keys: pathKey, run: cmdAppend},
+	"STRLEN":        {arity: 2, keys: pathKey, run: cmdStrLen},
+	"MERGE":         {arity: 3, write: true, keys: firstKey, run: cmdMerge},
+	"LPOP":          {arity: 2, write: true, keys: pathKey, shrinks: true, run: cmdPop},
+	"RPOP":          {arity: 2, write: true, keys: pathKey, shrinks: true, run: cmdPop},
+	"POP":           {arity: 2, write: true, keys: pathKey, shrinks: true, run: cmdPop},
+	"LRANGE":        {arity: 4, keys: pathKey, run: cmdLRange},
+	"LLEN":          {arity: 2, keys: pathKey, run: cmdLLen},
+	"LINDEX":        {arity: 3, keys: pathKey, run: cmdLIndex},
+	"LSET":          {arity: 5, write: true, keys: pathKey, run: cmdLSet},
+	"LREM":          {arity: 5, write: true, keys: pathKey, shrinks: true, run: cmdLRem},
+	"SADD":          {arity: 3, write: true, keys: firstKey, run: cmdSAdd},
+	"SREM":          {arity: 3, write: true, keys: firstKey, shrinks: true, run: cmdSRem},
+	"SISMEMBER":     {arity: 3, keys: firstKey, run: cmdSIsMember},
+	"SMEMBERS":      {arity: 2, keys: firstKey, run: cmdSMembers},
+	"SCARD":         {arity: 2, keys: firstKey, run: cmdSCard},
+	"SINTER":        {arity: 2, keys: allKeys, run: cmdSetAlgebra},
+	"SUNION":        {arity: 2, keys: allKeys, run: cmdSetAlgebra},
+	"SDIFF":         {arity: 2, keys: allKeys, run: cmdSetAlgebra},
+	"ZADD":          {arity: 4, write: true, keys: firstKey, run: cmdZAdd},
+	"ZREM":          {arity: 3, write: true, keys: firstKey, shrinks: true, run: cmdZRem},
+	"ZSCORE":        {arity: 3, keys: firstKey, run: cmdZScore},
+	"ZCARD":         {arity: 2, keys: firstKey, run: cmdZCard},
+	"ZRANK":         {arity: 3, keys: firstKey, run: cmdZRank},
+	"ZRANGE":        {arity: 4, keys: firstKey, run: cmdZRange},
+	"ZRANGEBYSCORE": {arity: 4, keys: firstKey, run: cmdZRangeByScore},
+	"PRINT":         {arity: 2, keys: pathKey, run: cmdPrint},
+	"GET":           {arity: 2, keys: pathKey, run: cmdGet},
+	"HISTORY":       {arity: 2, keys: firstKey, run: cmdHistory},
+	"REVERT":        {arity: 3, write: true, noMulti: true, keys: firstKey, logAs: logRevert, run: cmdRevert},
+	"EXPORT":        {arity: 2, noMulti: true, exclusive: true, run: cmdExport},
+	"IMPORT":        {arity: 2, noMulti: true, exclusive: true, run: cmdImport},
+	"INDEX":         {arity: 3, write: true, noMulti: true, run: cmdIndex},
+	"INDEXES":       {arity: 1, run: cmdIndexes},
+	"FIND":          {arity: 4, run: cmdFind},
+	"SELECT":        {arity: 4, run: cmdSelect},
+	"SUM":           {arity: 2, run: cmdAggregate},
+	"AVG":           {arity: 2, run: cmdAggregate},
+	"MIN":           {arity: 2, run: cmdAggregate},
+	"MAX":           {arity: 2, run: cmdAggregate},
+	"COUNT":         {arity: 2, run: cmdAggregate},
+	"EXPLAIN":       {arity: 2, run: cmdExplain},
+	"EXPIRE":        {arity: 3, write: true, keys: firstKey, absolute: absExpire, shrinks: true, run: cmdPExpireAt},
+	"PEXPIRE":       {arity: 3, write: true, keys: firstKey, absolute: absExpire, shrinks: true, run: cmdPExpireAt},
+	"PEXPIREAT":     {arity: 3, write: true, keys: firstKey, shrinks: true, run: cmdPExpireAt},
+	"PERSIST":       {arity: 2, write: true, keys: firstKey, shrinks: true, run: cmdPersist},
+	"TTL":           {arity: 2, keys: firstKey, run: cmdTTL},
+	"PTTL":          {arity: 2, keys: firstKey, run: cmdTTL},
+	"DEL":           {arity: 2, write: true, keys: allKeys, shrinks: true, run: cmdDel},
+	"EXISTS":        {arity: 2, keys: allKeys, run: cmdExists},
+	"KEYS":          {arity: 2, run: cmdKeys},
+	"SCAN":          {arity: 2, run: cmdScan},
+	"RENAME":        {arity: 3, write: true, keys: twoKeys, run: cmdRename},
+	"RENAMENX":      {arity: 3, write: true, keys: twoKeys, run: cmdRename},
+	"TYPE":          {arity: 2, keys: firstKey, run: cmdType},
+	"PING":          {arity: 1, run: cmdPing},
+	"INFO":          {arity: 1, run: cmdInfo},
+	"REWRITEAOF":    {arity: 1, noMulti: true, exclusive: true, run: cmdRewriteAOF},
+	"SAVE":          {arity: 1, noMulti: true, exclusive: true, run: cmdSave},
+	"LOAD":          {arity: 1, noMulti: true, exclusive: true, run: cmdLoad},
+}
+
+func firstKey(args []string) []string {
+	return args[1:2]
+}
+
+func twoKeys(args []string) []string {
+	return args[1:3]
+}
+
+func allKeys(args []string) []string {
+	return args[1:]
+}
+
+// pathKey is firstKey for commands whose first argument may be a path such
+// as "user.address.city" or "list[2]".
+func pathKey(args []string) []string {
+	return []string{core.PathRoot(args[1])}
+}
+
+const DefaultSnapshotPath = "dump.tbox"
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Executor runs commands against a TypeBox. Ordinary commands run in parallel
+// under the read side of mu and rely on the TypeBox shard locks; EXEC and the
+// exclusive commands take the write side. With the append-only log enabled,
+// writes are serialized too, so the log records them in the order they were
+// applied.
+type Executor struct {
+	mu           sync.RWMutex
+	tb           *storage.TypeBox
+	aof          *persist.AOF
+	snapshotPath string
+	strictPush   bool
+	// strictAggregates makes SUM, AVG, MIN and MAX fail on a non-numeric
+	// value instead of skipping it.
+	strictAggregates bool
+	// history records recent changes per key when enabled.
+	history *keyHistory
+}
+
+func NewExecutor(tb *storage.TypeBox) *Executor {
+	return &Executor{tb: tb, snapshotPath: DefaultSnapshotPath}
+}
+
+func (e *Executor) SetAOF(aof *persist.AOF) {
+	e.aof = aof
+}
+
+func (e *Executor) SetSnapshotPath(path string) {
+	e.snapshotPath = path
+}
+
+// SetStrictPush makes PUSH onto an existing non-list value fail with
+// core.ErrWrongKind instead of turning the value into a list.
+func (e *Executor) SetStrictPush(strict bool) {
+	e.strictPush = strict
+}
+
+// SetStrictAggregates makes SUM, AVG, MIN and MAX fail with
+// core.ErrWrongKind on a non-numeric value instead of skipping it.
+func (e *Executor) SetStrictAggregates(strict bool) {
+	e.strictAggregates = strict
+}
+
+// Subscribe opens a keyspace event subscription on the store; see
+// storage.TypeBox.Subscribe.
+func (e *Executor) Subscribe(buffer int) *storage.Subscription {
+	return e.tb.Subscribe(buffer)
+}
+
+// valueAt reads the value a key or path argument refers to. exists is false
+// only when a plain key is not set; a missing path is an error.
+func (e *Executor) valueAt(arg string) (interface{}, bool, error) {
+	key, path, err := core.ParsePath(arg)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(path) == 0 {
+		val, exists := e.tb.Get(key)
+		return val, exists, nil
+	}
+	val, err := e.tb.GetPath(key, path)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// updateAt atomically replaces the value a key or path argument refers to.
+func (e *Executor) updateAt(arg string, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	return e.updateAs(storage.EventSet, arg, fn)
+}
+
+// updateAs is updateAt publishing kind as the keyspace event.
+func (e *Executor) updateAs(kind storage.EventKind, arg string, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	key, path, err := core.ParsePath(arg)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		return e.tb.UpdateAs(kind, key, fn)
+	}
+	return e.tb.UpdatePathAs(kind, key, path, fn)
+}
+
+func lookup(args []string) (spec, error) {
+	if len(args) == 0 {
+		return spec{}, fmt.Errorf("%w: empty command", core.ErrWrongArity)
+	}
+	name := strings.ToUpper(args[0])
+	sp, ok := table[name]
+	if !ok {
+		return spec{}, fmt.Errorf("%w '%s'", ErrUnknownCommand, args[0])
+	}
+	if len(args) < sp.arity {
+		return spec{}, arityError(name)
+	}
+	return sp, nil
+}
+
+func (e *Executor) Exec(args []string) (interface{}, error) {
+	sp, err := lookup(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.tb.Err(); err != nil {
+		return nil, err
+	}
+
+	if sp.exclusive || (sp.write && (e.aof != nil || e.history != nil)) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	} else {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+
+	var logged [][]string
+	if sp.write && !sp.shrinks {
+		evicted, err := e.reclaim()
+		if err != nil {
+			return nil, errors.Join(err, e.log(evicted))
+		}
+		logged = evicted
+	}
+	var undo *writeUndo
+	if sp.write && e.aof != nil {
+		undo = e.saveUndo(sp, args)
+	}
+	before := e.versionsBefore(sp, args)
+	reply, logArgs, err := e.run(sp, args)
+	var logErr error
+	if logArgs != nil {
+		var cmds [][]string
+		cmds, logErr = e.logForm(sp, logArgs)
+		logged = append(logged, cmds...)
+	}
+	logErr = errors.Join(logErr, e.log(logged))
+	if undo != nil {
+		if logErr != nil && logArgs != nil {
+			undo.rollback(e.tb)
+		} else {
+			e.tb.ReleaseEvents(true)
+		}
+	}
+	if logErr != nil {
+		return nil, logErr
+	}
+	if logArgs != nil {
+		e.record(e.changesSince(sp, logArgs, before))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := e.tb.Err(); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// writeUndo is what it takes to roll back a write whose log append failed,
+// so the store never holds a change the log lacks. Keys evicted to make room
+// stay evicted: the log still having them only means a restart brings them
+// back.
+type writeUndo struct {
+	keys undoLog
+	// indexes is the index list before a command without keys, INDEX being
+	// the only such write.
+	indexes []storage.IndexInfo
+}
+
+// saveUndo records the state a write may change and holds the keyspace
+// events of its keys until the outcome is known.
+func (e *Executor) saveUndo(sp spec, args []string) *writeUndo {
+	u := &writeUndo{keys: make(undoLog)}
+	var keys []string
+	if sp.keys != nil {
+		keys = sp.keys(args)
+	} else {
+		u.indexes = e.tb.Indexes()
+	}
+	for _, key := range keys {
+		u.keys.save(e.tb, key)
+	}
+	e.tb.HoldEvents(keys)
+	return u
+}
+
+func (u *writeUndo) rollback(tb *storage.TypeBox) {
+	u.keys.restore(tb)
+	tb.ReleaseEvents(false)
+	if u.indexes == nil {
+		return
+	}
+	had := make(map[string]bool, len(u.indexes))
+	for _, info := range u.indexes {
+		had[info.Name] = true
+	}
+	now := make(map[string]bool)
+	for _, info := range tb.Indexes() {
+		now[info.Name] = true
+		if !had[info.Name] {
+			tb.DropIndex(info.Name)
+		}
+	}
+	for _, info := range u.indexes {
+		if !now[info.Name] {
+			tb.CreateIndex(info.Name, info.Field)
+		}
+	}
+}
+
+// reclaim makes room before a write that may grow the store. The evicted
+// keys come back as DEL commands for the log, so a replay does not bring
+// them back.
+func (e *Executor) reclaim() ([][]string, error) {
+	keys, err := e.tb.Reclaim()
+	dels := make([][]string, len(keys))
+	for i, key := range keys {
+		dels[i] = []string{"DEL", key}
+		if e.history != nil {
+			e.history.forget(key)
+		}
+	}
+	return dels, err
+}
+
+// logForm returns the commands that log the write args made.
+func (e *Executor) logForm(sp spec, args []string) ([][]string, error) {
+	if sp.logAs == nil {
+		return [][]string{args}, nil
+	}
+	return sp.logAs(e, args)
+}
+
+func (e *Executor) log(cmds [][]string) error {
+	if e.aof == nil || len(cmds) == 0 {
+		return nil
+	}
+	return e.aof.Append(cmds...)
+}
+
+// run executes one command with e.mu held and returns, for write commands,
+// the arguments to append to the log.
+func (e *Executor) run(sp spec, args []string) (interface{}, []string, error) {
+	if sp.absolute != nil {
+		var err error
+		if args, err = sp.absolute(e, args); err != nil {
+			return nil, nil, err
+		}
+	}
+	reply, err := sp.run(e, args)
+	if err != nil || !sp.write {
+		return reply, nil, err
+	}
+	return reply, args, nil
+}
+
+func arityError(name string) error {
+	return fmt.Errorf("%w for '%s'", core.ErrWrongArity, name)
+}
+
+// cmdObject takes the flattened form "OBJECT key n name TYPE value ...",
+// where a field of type OBJECT or LIST is itself followed by a count and its
+// own fields or elements.
+func cmdObject(e *Executor, args []string) (interface{}, error) {
+	n, err := strconv.Atoi(args[2])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid field count '%s'", core.ErrParse, args[2])
+	}
+	obj, rest, err := core.ParseFields(n, args[3:])
+	if errors.Is(err, core.ErrIncomplete) || (err == nil && len(rest) != 0) {
+		return nil, arityError("OBJECT")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := core.CheckKey(args[1]); err != nil {
+		return nil, err
+	}
+	e.tb.SaveObject(args[1], obj)
+	return OK, nil
+}
+
+func cmdPush(e *Executor, args []string) (interface{}, error) {
+	val, rest, err := core.ParseValue(args[2], args[3:])
+	if errors.Is(err, core.ErrIncomplete) || (err == nil && len(rest) != 0) {
+		return nil, arityError("PUSH")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.updateAs(storage.EventPush, args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if _, isList := old.(core.ListValue); e.strictPush && exists && !isList {
+			return nil, fmt.Errorf("%w: '%s' is not a list", core.ErrWrongKind, args[1])
+		}
+		return core.PushOnto(old, exists, val), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdMerge handles "MERGE target source [DEEP] [STRATEGY name]". The plain
+// form replies OK as it always has; with any option the reply lists the
+// fields that conflicted.
+func cmdMerge(e *Executor, args []string) (interface{}, error) {
+	var opts core.MergeOptions
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "DEEP":
+			opts.Deep = true
+		case "STRATEGY":
+			if i+1 == len(args) {
+				return nil, arityError("MERGE")
+			}
+			i++
+			strategy, err := core.ParseMergeStrategy(args[i])
+			if err != nil {
+				return nil, err
+			}
+			opts.Strategy = strategy
+		default:
+			return nil, fmt.Errorf("%w: unknown MERGE option '%s'", core.ErrParse, args[i])
+		}
+	}
+
+	conflicts, err := e.tb.MergeObjectsWith(args[1], args[2], opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 3 {
+		return OK, nil
+	}
+	reply := make([]interface{}, len(conflicts))
+	for i, path := range conflicts {
+		reply[i] = path
+	}
+	return reply, nil
+}
+
+// cmdPrint handles "PRINT key|path [JSON]".
+func cmdPrint(e *Executor, args []string) (interface{}, error) {
+	if len(args) > 3 {
+		return nil, arityError("PRINT")
+	}
+	asJSON := len(args) == 3
+	if asJSON && !strings.EqualFold(args[2], "JSON") {
+		return nil, fmt.Errorf("%w: unknown PRINT format '%s'", core.ErrParse, args[2])
+	}
+
+	val, exists, err := e.valueAt(args[1])
+	if err != nil || !exists {
+		return nil, err
+	}
+	if asJSON {
+		data, err := core.ToJSON(val)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return core.FormatValue(val), nil
+}
+
+func cmdExport(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("EXPORT")
+	}
+	if err := persist.ExportJSON(args[1], e.tb); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdImport stores every member of a JSON object under its name, replacing
+// existing keys and leaving the others alone. Like LOAD it rewrites the
+// append-only log instead of logging itself, since the file may change.
+func cmdImport(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("IMPORT")
+	}
+	values, err := persist.ImportJSON(args[1])
+	if err != nil {
+		return nil, err
+	}
+	for key := range values {
+		if err := core.CheckKey(key); err != nil {
+			return nil, err
+		}
+	}
+	var grow int64
+	for key, val := range values {
+		grow += storage.EntrySize(key, val)
+		if old, exists := e.tb.Peek(key); exists {
+			grow -= storage.EntrySize(key, old)
+		}
+	}
+	if err := e.fitsMemory(e.tb.MemoryInfo().Used + grow); err != nil {
+		return nil, err
+	}
+	for key, val := range values {
+		e.tb.Put(key, val)
+	}
+
+	if err := e.afterBulkWrite(); err != nil {
+		return nil, err
+	}
+	return len(values), nil
+}
+
+// fitsMemory refuses a LOAD or IMPORT that would leave the store at used
+// bytes, over the limit, when the policy is noeviction. Other policies let it
+// through and evict afterwards, as they would for a write.
+func (e *Executor) fitsMemory(used int64) error {
+	mem := e.tb.MemoryInfo()
+	if mem.Limit > 0 && mem.Policy == storage.NoEviction && used > mem.Limit {
+		return fmt.Errorf("%w: the data needs %s", storage.ErrOutOfMemory, storage.FormatMemorySize(used))
+	}
+	return nil
+}
+
+// afterBulkWrite brings the store back under the memory limit after LOAD or
+// IMPORT and rewrites the log to match. The evicted keys need no DEL in the
+// log, since the rewrite is taken afterwards. If the policy cannot make
+// enough room the data stays and ErrOutOfMemory reports it.
+func (e *Executor) afterBulkWrite() error {
+	_, reclaimErr := e.tb.Reclaim()
+	if e.aof != nil {
+		if err := e.aof.Rewrite(e.tb); err != nil {
+			return err
+		}
+	}
+	return reclaimErr
+}
+
+func cmdPing(e *Executor, args []string) (interface{}, error) {
+	if len(args) > 1 {
+		return args[1], nil
+	}
+	return Status("PONG"), nil
+}
+
+// cmdInfo handles "INFO [section]". The only section is memory, which
+// reports the estimated size of the store, the limit and the eviction count.
+func cmdInfo(e *Executor, args []string) (interface{}, error) {
+	if len(args) > 2 {
+		return nil, arityError("INFO")
+	}
+	if len(args) == 2 && !strings.EqualFold(args[1], "memory") {
+		return nil, fmt.Errorf("%w: unknown INFO section '%s'", core.ErrParse, args[1])
+	}
+	mem := e.tb.MemoryInfo()
+	return []interface{}{
+		"# Memory",
+		fmt.Sprintf("used_memory:%d", mem.Used),
+		"used_memory_human:" + storage.FormatMemorySize(mem.Used),
+		fmt.Sprintf("maxmemory:%d", mem.Limit),
+		"maxmemory_human:" + storage.FormatMemorySize(mem.Limit),
+		"maxmemory_policy:" + mem.Policy.String(),
+		fmt.Sprintf("evicted_keys:%d", mem.Evicted),
+	}, nil
+}
+
+func cmdRewriteAOF(e *Executor, args []string) (interface{}, error) {
+	if e.aof == nil {
+		return nil, errors.New("append-only log is disabled")
+	}
+	if err := e.aof.Rewrite(e.tb); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+func (e *Executor) snapshotArg(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return e.snapshotPath
+}
+
+func cmdSave(e *Executor, args []string) (interface{}, error) {
+	if err := persist.SaveSnapshot(e.snapshotArg(args), e.tb); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdLoad replaces the whole store with the snapshot contents and, when the
+// append-only log is on, rewrites the log so a restart sees the same data.
+// Like IMPORT it is held to maxmemory; see fitsMemory.
+func cmdLoad(e *Executor, args []string) (interface{}, error) {
+	loaded, err := persist.LoadSnapshot(e.snapshotArg(args))
+	if err != nil {
+		return nil, err
+	}
+	loaded.Range(func(key string, _ interface{}) bool {
+		err = core.CheckKey(key)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.fitsMemory(loaded.MemoryInfo().Used); err != nil {
+		return nil, err
+	}
+
+	e.tb.Clear()
+	loaded.Range(func(key string, val interface{}) bool {
+		e.tb.Put(key, val)
+		if deadline, ok := loaded.Expiry(key); ok {
+			e.tb.ExpireAt(key, deadline)
+		}
+		return true
+	})
+
+	if err := e.afterBulkWrite(); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
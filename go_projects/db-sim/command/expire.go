@@ -0,0 +1,179 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func parseMillis(raw string) (int64, error) {
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid time '%s'", core.ErrParse, raw)
+	}
+	return v, nil
+}
+
+// absSet turns "SET key TYPE value EX s|PX ms" into "... PXAT unix-ms". The
+// time must be positive: unlike EXPIRE, SET does not delete the key it was
+// asked to write.
+func absSet(e *Executor, args []string) ([]string, error) {
+	if len(args) != 6 {
+		return args, nil
+	}
+	var unit time.Duration
+	switch strings.ToUpper(args[4]) {
+	case "EX":
+		unit = time.Second
+	case "PX":
+		unit = time.Millisecond
+	default:
+		return args, nil
+	}
+	n, err := parseMillis(args[5])
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: invalid expire time '%s' in 'SET'", core.ErrParse, args[5])
+	}
+	deadline := e.tb.Now().Add(time.Duration(n) * unit)
+	return append(args[:4:4], "PXAT", strconv.FormatInt(deadline.UnixMilli(), 10)), nil
+}
+
+func cmdSet(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 4 && len(args) != 6 {
+		return nil, arityError("SET")
+	}
+	var deadline time.Time
+	if len(args) == 6 {
+		if strings.ToUpper(args[4]) != "PXAT" {
+			return nil, fmt.Errorf("%w: unknown SET option '%s'", core.ErrParse, args[4])
+		}
+		ms, err := parseMillis(args[5])
+		if err != nil {
+			return nil, err
+		}
+		deadline = time.UnixMilli(ms)
+	}
+
+	key, path, err := core.ParsePath(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 {
+		if !deadline.IsZero() {
+			return nil, fmt.Errorf("%w: expiry applies to whole keys, not to '%s'", core.ErrParse, args[1])
+		}
+		val, err := core.ParsePrimitive(args[2], args[3])
+		if err != nil {
+			return nil, err
+		}
+		err = e.tb.UpdatePath(key, path, func(interface{}, bool) (interface{}, error) {
+			return val, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return OK, nil
+	}
+
+	if err := e.tb.SetScalar(args[1], args[2], args[3]); err != nil {
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		e.tb.ExpireAt(args[1], deadline)
+	}
+	return OK, nil
+}
+
+// absExpire turns EXPIRE and PEXPIRE into PEXPIREAT.
+func absExpire(e *Executor, args []string) ([]string, error) {
+	if len(args) != 3 {
+		return nil, arityError(strings.ToUpper(args[0]))
+	}
+	n, err := parseMillis(args[2])
+	if err != nil {
+		return nil, err
+	}
+	unit := time.Millisecond
+	if strings.ToUpper(args[0]) == "EXPIRE" {
+		unit = time.Second
+	}
+	deadline := e.tb.Now().Add(time.Duration(n) * unit)
+	return []string{"PEXPIREAT", args[1], strconv.FormatInt(deadline.UnixMilli(), 10)}, nil
+}
+
+func cmdPExpireAt(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("PEXPIREAT")
+	}
+	ms, err := parseMillis(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if e.tb.ExpireAt(args[1], time.UnixMilli(ms)) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func cmdPersist(e *Executor, args []string) (interface{}, error) {
+	if e.tb.Persist(args[1]) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// cmdTTL answers TTL in seconds and PTTL in milliseconds; -2 means the key
+// does not exist and -1 that it never expires.
+func cmdTTL(e *Executor, args []string) (interface{}, error) {
+	ttl, exists, hasTTL := e.tb.TTL(args[1])
+	switch {
+	case !exists:
+		return -2, nil
+	case !hasTTL:
+		return -1, nil
+	case strings.ToUpper(args[0]) == "PTTL":
+		return int(ttl.Milliseconds()), nil
+	default:
+		return int((ttl + 500*time.Millisecond) / time.Second), nil
+	}
+}
+
+const sweepSample = 20
+
+// StartExpirySweeper removes expired keys in the background, one sampling
+// round every interval, until the returned stop function is called.
+func (e *Executor) StartExpirySweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.mu.RLock()
+				e.tb.SweepExpired(sweepSample)
+				e.mu.RUnlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestSet_RejectsNonPositiveExpireTime(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "SET k INT 1")
+	for _, cmd := range []string{"SET k INT 2 EX 0", "SET k INT 2 EX -1", "SET k INT 2 PX 0", "SET fresh INT 2 PX -5"} {
+		if _, err := run(t, s, cmd); !errors.Is(err, core.ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", cmd, err)
+		}
+	}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"PRINT k", "1"},
+		{"TTL k", "-1"},
+		{"EXISTS fresh", "0"},
+		{"EXPIRE k 0", "1"},
+		{"EXISTS k", "0"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package command
+
+import (
+	"sort"
+	"strings"
+)
+
+// Help describes one command for HELP in the interactive shell.
+type Help struct {
+	Usage   string
+	Summary string
+}
+
+var helpText = map[string]Help{
+	"SET":           {"SET key|path TYPE value [EX seconds|PX ms|PXAT unix-ms]", "Store a scalar, optionally with an expiry."},
+	"OBJECT":        {"OBJECT key n name TYPE value ...", "Store an object of n fields; with only 'OBJECT key n' the fields follow on their own lines."},
+	"PUSH":          {"PUSH key|path TYPE value", "Append a value to a list, creating the list when needed."},
+	"INCR":          {"INCR key|path", "Add 1 to an INT or FLOAT, starting from 0."},
+	"INCRBY":        {"INCRBY key|path n", "Add an integer to an INT or FLOAT."},
+	"INCRBYFLOAT":   {"INCRBYFLOAT key|path x", "Add a float; the result is a FLOAT."},
+	"APPEND":        {"APPEND key|path text", "Append to a STRING and return its new length."},
+	"STRLEN":        {"STRLEN key|path", "Length of a STRING in characters."},
+	"MERGE":         {"MERGE target source [DEEP] [STRATEGY source|target|error|concat-lists]", "Merge the fields of source into target."},
+	"LPOP":          {"LPOP key|path [count]", "Remove and return values from the head of a list."},
+	"RPOP":          {"RPOP key|path [count]", "Remove and return values from the tail of a list."},
+	"POP":           {"POP key|path [count]", "Same as RPOP."},
+	"LRANGE":        {"LRANGE key|path start stop", "Return list elements between two inclusive indexes."},
+	"LLEN":          {"LLEN key|path", "Return the length of a list."},
+	"LINDEX":        {"LINDEX key|path index", "Return one list element."},
+	"LSET":          {"LSET key|path index TYPE value", "Replace one list element."},
+	"LREM":          {"LREM key|path count TYPE value", "Remove up to count elements equal to value."},
+	"SADD":          {"SADD key member [member ...]", "Add members to a set and return how many were new."},
+	"SREM":          {"SREM key member [member ...]", "Remove members from a set; the key goes when the set is empty."},
+	"SISMEMBER":     {"SISMEMBER key member", "1 if member is in the set, else 0."},
+	"SMEMBERS":      {"SMEMBERS key", "List the members of a set in sorted order."},
+	"SCARD":         {"SCARD key", "Number of members in a set."},
+	"SINTER":        {"SINTER key [key ...]", "Members found in every set."},
+	"SUNION":        {"SUNION key [key ...]", "Members found in any of the sets."},
+	"SDIFF":         {"SDIFF key [key ...]", "Members of the first set found in none of the others."},
+	"ZADD":          {"ZADD key score member [score member ...]", "Add members to a sorted set or change their scores."},
+	"ZREM":          {"ZREM key member [member ...]", "Remove members from a sorted set."},
+	"ZSCORE":        {"ZSCORE key member", "Score of a member."},
+	"ZCARD":         {"ZCARD key", "Number of members in a sorted set."},
+	"ZRANK":         {"ZRANK key member", "0-based position of a member in score order."},
+	"ZRANGE":        {"ZRANGE key start stop [WITHSCORES]", "Members between two inclusive ranks in score order."},
+	"ZRANGEBYSCORE": {"ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]", "Members with scores in a range; -inf, +inf and (exclusive bounds allowed."},
+	"PRINT":         {"PRINT key|path [JSON]", "Print a value, optionally as JSON."},
+	"GET":           {"GET key|path [AT version]", "Print a value, or the value it had at a version from HISTORY."},
+	"HISTORY":       {"HISTORY key", "List the recorded changes of a key: version, time and command."},
+	"REVERT":        {"REVERT key version", "Restore the value a key had at a version from HISTORY."},
+	"EXPORT":        {"EXPORT file.json", "Write every key to a JSON object file."},
+	"IMPORT":        {"IMPORT file.json", "Store each member of a JSON object file under its name."},
+	"EXPIRE":        {"EXPIRE key seconds", "Set a key's time to live."},
+	"PEXPIRE":       {"PEXPIRE key ms", "Set a key's time to live in milliseconds."},
+	"PEXPIREAT":     {"PEXPIREAT key unix-ms", "Expire a key at an absolute time."},
+	"PERSIST":       {"PERSIST key", "Remove a key's expiry."},
+	"TTL":           {"TTL key", "Seconds until a key expires, -1 without expiry, -2 if missing."},
+	"PTTL":          {"PTTL key", "Like TTL, in milliseconds."},
+	"DEL":           {"DEL key [key ...]", "Delete keys and return how many existed."},
+	"EXISTS":        {"EXISTS key [key ...]", "Count how many of the keys exist."},
+	"KEYS":          {"KEYS pattern", "List the keys matching a glob pattern."},
+	"SCAN":          {"SCAN cursor [MATCH pattern] [COUNT n]", "Iterate over the keys a page at a time."},
+	"RENAME":        {"RENAME key newkey", "Rename a key, replacing newkey."},
+	"RENAMENX":      {"RENAMENX key newkey", "Rename a key only if newkey does not exist."},
+	"TYPE":          {"TYPE key", "Name the kind of value stored at key."},
+	"PING":          {"PING [message]", "Check the connection."},
+	"INFO":          {"INFO [memory]", "Report memory usage, the maxmemory limit and policy, and how many keys were evicted."},
+	"REWRITEAOF":    {"REWRITEAOF", "Compact the append-only log."},
+	"SAVE":          {"SAVE [file]", "Write a snapshot of the store."},
+	"LOAD":          {"LOAD [file]", "Replace the store with a snapshot."},
+	"INDEX":         {"INDEX CREATE name ON field | INDEX DROP name", "Create or drop a secondary index on an object field."},
+	"INDEXES":       {"INDEXES", "List the secondary indexes."},
+	"FIND":          {"FIND field =|!=|>|>=|<|<= value | FIND field IN (v1, v2, ...)", "List the keys of objects whose field matches, using an index when one exists."},
+	"SELECT":        {"SELECT f1, f2|* FROM pattern [WHERE cond [AND cond ...]] [ORDER BY field [ASC|DESC]] [LIMIT n [OFFSET m]] [FORMAT TABLE|JSON]", "Query the objects whose keys match a glob pattern."},
+	"SUM":           {"SUM key|path | SUM field FROM pattern [WHERE ...] [GROUP BY field]", "Add up a list, or a field across objects; INT while every value is an INT."},
+	"AVG":           {"AVG key|path | AVG field FROM pattern [WHERE ...] [GROUP BY field]", "Average as a FLOAT."},
+	"MIN":           {"MIN key|path | MIN field FROM pattern [WHERE ...] [GROUP BY field]", "Smallest number, keeping its type."},
+	"MAX":           {"MAX key|path | MAX field FROM pattern [WHERE ...] [GROUP BY field]", "Largest number, keeping its type."},
+	"COUNT":         {"COUNT key|path | COUNT field|* FROM pattern [WHERE ...] [GROUP BY field]", "Count list elements, fields or objects."},
+	"EXPLAIN":       {"EXPLAIN FIND ... | EXPLAIN SELECT ...", "Show whether a query would use an index or a full scan."},
+	"MULTI":         {"MULTI", "Start queueing a transaction."},
+	"EXEC":          {"EXEC", "Run the queued transaction."},
+	"DISCARD":       {"DISCARD", "Drop the queued transaction."},
+	"WATCH":         {"WATCH key [key ...]", "Abort the next EXEC if any of the keys change."},
+	"UNWATCH":       {"UNWATCH", "Forget all watched keys."},
+}
+
+// LookupHelp returns the help for a command name in any case.
+func LookupHelp(name string) (Help, bool) {
+	h, ok := helpText[strings.ToUpper(name)]
+	return h, ok
+}
+
+// CommandNames lists every command the executor and sessions accept.
+func CommandNames() []string {
+	names := make([]string, 0, len(helpText))
+	for name := range helpText {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
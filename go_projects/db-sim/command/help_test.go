@@ -0,0 +1,11 @@
+package command
+
+import "testing"
+
+func TestHelp_CoversEveryCommand(t *testing.T) {
+	for name := range table {
+		if _, ok := LookupHelp(name); !ok {
+			t.Errorf("no help for %s", name)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/parser"
+	"github.com/dim4d/DbSim/persist"
+)
+
+var (
+	ErrNoSuchVersion   = errors.New("no such version")
+	errHistoryDisabled = errors.New("key history is disabled")
+)
+
+// historyEntry is one change to a key: the command that made it and a deep
+// copy of the value it left behind, so later writes to the live value can
+// never reach back into history.
+type historyEntry struct {
+	version int
+	time    time.Time
+	command []string
+	val     interface{}
+}
+
+// keyHistory keeps the last depth entries of every key that was written
+// while history was on. Versions count up from 1 per key and are never
+// reused while the key exists, so old versions simply fall off the front.
+// Deleting a key, or evicting it, drops its history, so a key created again
+// starts over. Commands that replace the whole store, such as LOAD and
+// IMPORT, are not recorded.
+type keyHistory struct {
+	mu    sync.Mutex
+	depth int
+	keys  map[string][]historyEntry
+	next  map[string]int
+}
+
+func newKeyHistory(depth int) *keyHistory {
+	return &keyHistory{
+		depth: depth,
+		keys:  make(map[string][]historyEntry),
+		next:  make(map[string]int),
+	}
+}
+
+func (h *keyHistory) add(key string, entry historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.next[key]++
+	entry.version = h.next[key]
+	entries := append(h.keys[key], entry)
+	if len(entries) > h.depth {
+		entries = append([]historyEntry(nil), entries[len(entries)-h.depth:]...)
+	}
+	h.keys[key] = entries
+}
+
+func (h *keyHistory) forget(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.keys, key)
+	delete(h.next, key)
+}
+
+func (h *keyHistory) entries(key string) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]historyEntry(nil), h.keys[key]...)
+}
+
+func (h *keyHistory) at(key string, version int) (historyEntry, error) {
+	for _, entry := range h.entries(key) {
+		if entry.version == version {
+			return entry, nil
+		}
+	}
+	return historyEntry{}, fmt.Errorf("%w %d of '%s' in history", ErrNoSuchVersion, version, key)
+}
+
+// SetHistoryDepth turns on key history, keeping the last depth changes of
+// every key, or turns it off when depth is 0. Call it before the executor is
+// shared.
+func (e *Executor) SetHistoryDepth(depth int) {
+	if depth <= 0 {
+		e.history = nil
+		return
+	}
+	e.history = newKeyHistory(depth)
+}
+
+// pendingChange is a write whose history entries are not recorded yet. A
+// change without a command deleted the key.
+type pendingChange struct {
+	key   string
+	entry historyEntry
+}
+
+// versionsBefore reads the versions of the keys a write is about to touch,
+// so changesSince can leave out keys the command did not change.
+func (e *Executor) versionsBefore(sp spec, args []string) []uint64 {
+	if e.history == nil || !sp.write || sp.keys == nil {
+		return nil
+	}
+	keys := sp.keys(args)
+	versions := make([]uint64, len(keys))
+	for i, key := range keys {
+		versions[i] = e.tb.Version(key)
+	}
+	return versions
+}
+
+func (e *Executor) changesSince(sp spec, args []string, before []uint64) []pendingChange {
+	if before == nil {
+		return nil
+	}
+	var changes []pendingChange
+	now := e.tb.Now()
+	for i, key := range sp.keys(args) {
+		if e.tb.Version(key) == before[i] {
+			continue
+		}
+		val, exists := e.tb.Peek(key)
+		if !exists {
+			changes = append(changes, pendingChange{key: key})
+			continue
+		}
+		changes = append(changes, pendingChange{key, historyEntry{time: now, command: args, val: core.Clone(val)}})
+	}
+	return changes
+}
+
+func (e *Executor) record(changes []pendingChange) {
+	for _, c := range changes {
+		if c.entry.command == nil {
+			e.history.forget(c.key)
+			continue
+		}
+		e.history.add(c.key, c.entry)
+	}
+}
+
+// cmdHistory lists the recorded changes of a key, oldest first, as
+// "version time command" lines.
+func cmdHistory(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("HISTORY")
+	}
+	if e.history == nil {
+		return nil, errHistoryDisabled
+	}
+	entries := e.history.entries(args[1])
+	lines := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		lines[i] = fmt.Sprintf("%d %s %s", entry.version, entry.time.UTC().Format(time.RFC3339Nano), parser.Join(entry.command))
+	}
+	return lines, nil
+}
+
+func parseVersion(raw string) (int, error) {
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("%w: invalid version '%s'", core.ErrParse, raw)
+	}
+	return v, nil
+}
+
+// cmdGet handles "GET key|path [AT version]". Without AT it reads the
+// current value, like PRINT; with it, the value the key had right after that
+// version.
+func cmdGet(e *Executor, args []string) (interface{}, error) {
+	if len(args) == 2 {
+		val, exists, err := e.valueAt(args[1])
+		if err != nil || !exists {
+			return nil, err
+		}
+		return core.FormatValue(val), nil
+	}
+	if len(args) != 4 || !strings.EqualFold(args[2], "AT") {
+		return nil, arityError("GET")
+	}
+	version, err := parseVersion(args[3])
+	if err != nil {
+		return nil, err
+	}
+	if e.history == nil {
+		return nil, errHistoryDisabled
+	}
+
+	key, path, err := core.ParsePath(args[1])
+	if err != nil {
+		return nil, err
+	}
+	entry, err := e.history.at(key, version)
+	if err != nil {
+		return nil, err
+	}
+	val := entry.val
+	if len(path) > 0 {
+		if val, err = core.GetPath(key, val, path); err != nil {
+			return nil, err
+		}
+	}
+	return core.FormatValue(val), nil
+}
+
+// cmdRevert handles "REVERT key version", putting back the value key had at
+// that version. The revert is itself recorded as a new version. Replaying it would need the history, so
+// logRevert logs the value it left instead.
+func cmdRevert(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("REVERT")
+	}
+	version, err := parseVersion(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if e.history == nil {
+		return nil, errHistoryDisabled
+	}
+	key := args[1]
+	entry, err := e.history.at(key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	e.tb.Put(key, entry.val)
+	return OK, nil
+}
+
+// logRevert logs a revert as a DEL of the key followed by the commands that
+// rebuild the value it was reverted to.
+func logRevert(e *Executor, args []string) ([][]string, error) {
+	key := args[1]
+	val, _ := e.tb.Peek(key)
+	rebuild, err := persist.RewriteCommands(key, val)
+	if err != nil {
+		return nil, err
+	}
+	return append([][]string{{"DEL", key}}, rebuild...), nil
+}
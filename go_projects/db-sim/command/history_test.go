@@ -0,0 +1,149 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/persist"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func historySession(depth int) (*Session, *storage.TypeBox) {
+	tb := storage.NewTypeBox()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tb.SetClock(func() time.Time { return now })
+	e := NewExecutor(tb)
+	e.SetHistoryDepth(depth)
+	return e.NewSession(), tb
+}
+
+func TestHistory_GetAtAndRevert(t *testing.T) {
+	s, _ := historySession(10)
+	mustRun(t, s, "SET a STRING first")
+	mustRun(t, s, "SET a INT 2")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"HISTORY a", `[1 2024-05-01T12:00:00Z SET a STRING first 2 2024-05-01T12:00:00Z SET a INT 2]`},
+		{"HISTORY missing", "[]"},
+		{"GET a AT 1", "first"},
+		{"GET a AT 2", "2"},
+		{"REVERT a 1", "OK"},
+		{"GET a", "first"},
+		{"HISTORY a", `[1 2024-05-01T12:00:00Z SET a STRING first 2 2024-05-01T12:00:00Z SET a INT 2 3 2024-05-01T12:00:00Z REVERT a 1]`},
+		{"DEL a missing", "1"},
+		{"HISTORY a", "[]"},
+		{"SET a INT 9", "OK"},
+		{"HISTORY a", "[1 2024-05-01T12:00:00Z SET a INT 9]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+	if _, err := run(t, s, "REVERT a 2"); !errors.Is(err, ErrNoSuchVersion) {
+		t.Errorf("REVERT of a version from before DEL: got %v, want ErrNoSuchVersion", err)
+	}
+}
+
+func TestHistory_PathsAndBound(t *testing.T) {
+	s, _ := historySession(2)
+	mustRun(t, s, "OBJECT u 1 name STRING ann")
+	mustRun(t, s, "SET u.name STRING bob")
+	mustRun(t, s, "SET u.name STRING cy")
+
+	if got := fmt.Sprint(mustRun(t, s, "GET u.name AT 2")); got != "bob" {
+		t.Errorf("GET u.name AT 2: got %s", got)
+	}
+	if _, err := run(t, s, "GET u AT 1"); !errors.Is(err, ErrNoSuchVersion) {
+		t.Errorf("version past the bound: got %v, want ErrNoSuchVersion", err)
+	}
+}
+
+func TestHistory_SkipsUnchangedKeysAndAbortedTransactions(t *testing.T) {
+	s, _ := historySession(10)
+	mustRun(t, s, "SET a INT 1")
+	mustRun(t, s, "SREM b x")
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "SET a INT 5")
+	mustRun(t, s, "LPOP a")
+	if _, err := run(t, s, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Fatalf("EXEC: got %v", err)
+	}
+
+	if got := fmt.Sprint(mustRun(t, s, "HISTORY a")); got != "[1 2024-05-01T12:00:00Z SET a INT 1]" {
+		t.Errorf("HISTORY a: got %s", got)
+	}
+	if got := fmt.Sprint(mustRun(t, s, "HISTORY b")); got != "[]" {
+		t.Errorf("HISTORY b: got %s", got)
+	}
+}
+
+func TestHistory_Disabled(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	for _, cmd := range []string{"HISTORY a", "GET a AT 1", "REVERT a 1"} {
+		if _, err := run(t, s, cmd); !errors.Is(err, errHistoryDisabled) {
+			t.Errorf("%s: got %v", cmd, err)
+		}
+	}
+}
+
+func TestHistory_RevertDoesNotRewriteHistory(t *testing.T) {
+	s, _ := historySession(10)
+	for i := 1; i <= 4; i++ {
+		mustRun(t, s, fmt.Sprintf("PUSH l INT %d", i))
+	}
+	if got := fmt.Sprint(mustRun(t, s, "GET l AT 4")); got != "[1,2,3,4]" {
+		t.Fatalf("GET l AT 4: got %s", got)
+	}
+	mustRun(t, s, "REVERT l 3")
+	mustRun(t, s, "PUSH l INT 5")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"GET l AT 3", "[1,2,3]"},
+		{"GET l AT 4", "[1,2,3,4]"},
+		{"GET l AT 5", "[1,2,3]"},
+		{"GET l", "[1,2,3,5]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestHistory_RevertIsLoggedAsItsValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+	e := NewExecutor(storage.NewTypeBox())
+	e.SetHistoryDepth(10)
+	aof, err := persist.OpenAOF(path, persist.FsyncAlways, func([]string) error { return nil })
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	e.SetAOF(aof)
+	s := e.NewSession()
+	mustRun(t, s, "PUSH l INT 1")
+	mustRun(t, s, "PUSH l INT 2")
+	mustRun(t, s, "PUSH l INT 3")
+	mustRun(t, s, "REVERT l 2")
+	aof.Close()
+
+	data, _ := os.ReadFile(path)
+	want := "PUSH l INT 1\nPUSH l INT 2\nPUSH l INT 3\nDEL l\nPUSH l INT 1\nPUSH l INT 2\n"
+	if string(data) != want {
+		t.Fatalf("log:\n%s\nwant:\n%s", data, want)
+	}
+	replayed, _ := openWithAOF(t, path)
+	if got := fmt.Sprint(mustRun(t, replayed, "PRINT l")); got != "[1,2]" {
+		t.Errorf("replayed l = %s", got)
+	}
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func cmdDel(e *Executor, args []string) (interface{}, error) {
+	deleted := 0
+	for _, key := range args[1:] {
+		if e.tb.Delete(key) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func cmdExists(e *Executor, args []string) (interface{}, error) {
+	found := 0
+	for _, key := range args[1:] {
+		if _, exists := e.tb.Get(key); exists {
+			found++
+		}
+	}
+	return found, nil
+}
+
+func cmdKeys(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("KEYS")
+	}
+	var keys []string
+	e.tb.Range(func(key string, _ interface{}) bool {
+		if core.MatchGlob(args[1], key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	sort.Strings(keys)
+
+	reply := make([]interface{}, len(keys))
+	for i, key := range keys {
+		reply[i] = key
+	}
+	return reply, nil
+}
+
+// cmdScan handles "SCAN cursor [MATCH pattern] [COUNT n]". Like KEYS it
+// filters with MATCH, but after picking COUNT keys, so a page may be short or
+// empty while the returned cursor is still non-zero.
+func cmdScan(e *Executor, args []string) (interface{}, error) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor '%s'", core.ErrParse, args[1])
+	}
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return nil, arityError("SCAN")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = parseInt(args[i+1]); err != nil {
+				return nil, err
+			}
+			if count <= 0 {
+				return nil, fmt.Errorf("%w: COUNT must be positive", core.ErrParse)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown SCAN option '%s'", core.ErrParse, args[i])
+		}
+	}
+
+	keys, next := e.tb.Scan(cursor, count)
+	page := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if core.MatchGlob(pattern, key) {
+			page = append(page, key)
+		}
+	}
+	return []interface{}{strconv.FormatUint(next, 10), page}, nil
+}
+
+func cmdRename(e *Executor, args []string) (interface{}, error) {
+	name := strings.ToUpper(args[0])
+	if len(args) != 3 {
+		return nil, arityError(name)
+	}
+	if err := core.CheckKey(args[2]); err != nil {
+		return nil, err
+	}
+	renamed, err := e.tb.Rename(args[1], args[2], name == "RENAMENX")
+	if err != nil {
+		return nil, err
+	}
+	if name == "RENAME" {
+		return OK, nil
+	}
+	if renamed {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func cmdType(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("TYPE")
+	}
+	val, exists := e.tb.Get(args[1])
+	if !exists {
+		return "none", nil
+	}
+	return core.KindOf(val), nil
+}
@@ -0,0 +1,39 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// Keys that read as paths must never be created: the AOF would log them as
+// paths and fail to replay.
+func TestKeys_PathLikeNamesSurviveRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+	s, aof := openWithAOF(t, path)
+	mustRun(t, s, "SET x INT 1")
+	for _, cmd := range []string{
+		"RENAME x a.b",
+		"RENAMENX x a[0]",
+		"OBJECT a.b 1 f INT 1",
+		"SADD a.b m",
+		"ZADD a[1] 1 m",
+	} {
+		if _, err := run(t, s, cmd); !errors.Is(err, core.ErrParse) {
+			t.Errorf("%s: got %v, want a parse error", cmd, err)
+		}
+	}
+	mustRun(t, s, "REWRITEAOF")
+	if err := aof.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, aof = openWithAOF(t, path)
+	defer aof.Close()
+	if got := fmt.Sprint(mustRun(t, s, "KEYS *")); got != "[x]" {
+		t.Errorf("KEYS * after replay: got %s", got)
+	}
+}
@@ -0,0 +1,313 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// Lists handed out by the store are shared with readers, so every command
+// that shrinks or edits a list builds a new slice instead of reslicing.
+
+func asList(arg string, val interface{}) (core.ListValue, error) {
+	list, ok := val.(core.ListValue)
+	if !ok {
+		return core.ListValue{}, fmt.Errorf("%w: '%s' is not a list", core.ErrWrongKind, arg)
+	}
+	return list, nil
+}
+
+func parseInt(raw string) (int, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer '%s'", core.ErrParse, raw)
+	}
+	return n, nil
+}
+
+func parseListValue(name string, args []string) (interface{}, error) {
+	val, rest, err := core.ParseValue(args[0], args[1:])
+	if errors.Is(err, core.ErrIncomplete) || (err == nil && len(rest) != 0) {
+		return nil, arityError(name)
+	}
+	return val, err
+}
+
+func formatAll(items []interface{}) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = core.FormatValue(item)
+	}
+	return out
+}
+
+// cmdPop handles "LPOP|RPOP key [count]"; POP is RPOP, the end PUSH adds to.
+// Without a count it replies with one value, with a count with an array.
+func cmdPop(e *Executor, args []string) (interface{}, error) {
+	name := strings.ToUpper(args[0])
+	if len(args) > 3 {
+		return nil, arityError(name)
+	}
+	count := 1
+	if len(args) == 3 {
+		n, err := parseInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("%w: count must be positive", core.ErrParse)
+		}
+		count = n
+	}
+
+	var popped []interface{}
+	missing := false
+	err := e.shrinkListAt(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			missing = true
+			return nil, errMissing
+		}
+		list, err := asList(args[1], old)
+		if err != nil {
+			return nil, err
+		}
+		n := min(count, len(list.Data))
+		rest := make([]interface{}, 0, len(list.Data)-n)
+		if name == "LPOP" {
+			popped = append(popped, list.Data[:n]...)
+			rest = append(rest, list.Data[n:]...)
+		} else {
+			for i := len(list.Data) - 1; i >= len(list.Data)-n; i-- {
+				popped = append(popped, list.Data[i])
+			}
+			rest = append(rest, list.Data[:len(list.Data)-n]...)
+		}
+		return core.ListValue{Data: rest}, nil
+	})
+	if missing {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 3 {
+		return formatAll(popped), nil
+	}
+	if len(popped) == 0 {
+		return nil, nil
+	}
+	return core.FormatValue(popped[0]), nil
+}
+
+// errMissing aborts an update of a key that does not exist without turning
+// into a reply.
+var errMissing = errors.New("missing")
+
+// shrinkListAt is updateAt for commands that remove list elements. A list
+// stored directly under a key is deleted once emptied, as sets are: an empty
+// list would otherwise linger in TYPE and KEYS but vanish on an AOF rewrite,
+// which has no command for it. Lists inside other values are kept.
+func (e *Executor) shrinkListAt(arg string, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	key, path, err := core.ParsePath(arg)
+	if err != nil {
+		return err
+	}
+	if len(path) > 0 {
+		return e.tb.UpdatePath(key, path, fn)
+	}
+	return e.tb.UpdateOrDelete(key, func(old interface{}, exists bool) (interface{}, bool, error) {
+		val, err := fn(old, exists)
+		if err != nil {
+			return nil, false, err
+		}
+		list, ok := val.(core.ListValue)
+		return val, !ok || len(list.Data) > 0, nil
+	})
+}
+
+// cmdLRange handles "LRANGE key start stop"; both ends are inclusive and
+// negative indexes count from the end.
+func cmdLRange(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 4 {
+		return nil, arityError("LRANGE")
+	}
+	start, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInt(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	val, exists, err := e.valueAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []interface{}{}, nil
+	}
+	list, err := asList(args[1], val)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(list.Data)
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []interface{}{}, nil
+	}
+	return formatAll(list.Data[start : stop+1]), nil
+}
+
+func cmdLLen(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("LLEN")
+	}
+	val, exists, err := e.valueAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	list, err := asList(args[1], val)
+	if err != nil {
+		return nil, err
+	}
+	return len(list.Data), nil
+}
+
+func cmdLIndex(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("LINDEX")
+	}
+	idx, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	val, exists, err := e.valueAt(args[1])
+	if err != nil || !exists {
+		return nil, err
+	}
+	list, err := asList(args[1], val)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 {
+		idx += len(list.Data)
+	}
+	if idx < 0 || idx >= len(list.Data) {
+		return nil, nil
+	}
+	return core.FormatValue(list.Data[idx]), nil
+}
+
+// cmdLSet handles "LSET key index TYPE value".
+func cmdLSet(e *Executor, args []string) (interface{}, error) {
+	idx, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	newVal, err := parseListValue("LSET", args[3:])
+	if err != nil {
+		return nil, err
+	}
+
+	err = e.updateAt(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return nil, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, args[1])
+		}
+		list, err := asList(args[1], old)
+		if err != nil {
+			return nil, err
+		}
+		i := idx
+		if i < 0 {
+			i += len(list.Data)
+		}
+		if i < 0 || i >= len(list.Data) {
+			return nil, fmt.Errorf("%w: index %d out of range", core.ErrNoSuchPath, idx)
+		}
+		data := make([]interface{}, len(list.Data))
+		copy(data, list.Data)
+		data[i] = newVal
+		return core.ListValue{Data: data}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdLRem handles "LREM key count TYPE value": it removes up to count equal
+// elements from the head (count > 0), from the tail (count < 0) or all of
+// them (count == 0). INT 5 and FLOAT 5 are different values.
+func cmdLRem(e *Executor, args []string) (interface{}, error) {
+	count, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	target, err := parseListValue("LREM", args[3:])
+	if err != nil {
+		return nil, err
+	}
+
+	removed := 0
+	missing := false
+	err = e.shrinkListAt(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			missing = true
+			return nil, errMissing
+		}
+		list, err := asList(args[1], old)
+		if err != nil {
+			return nil, err
+		}
+
+		limit := count
+		if limit < 0 {
+			limit = -limit
+		}
+		drop := make([]bool, len(list.Data))
+		for j := 0; j < len(list.Data); j++ {
+			i := j
+			if count < 0 {
+				i = len(list.Data) - 1 - j
+			}
+			if limit > 0 && removed == limit {
+				break
+			}
+			if reflect.DeepEqual(list.Data[i], target) {
+				drop[i] = true
+				removed++
+			}
+		}
+
+		data := make([]interface{}, 0, len(list.Data)-removed)
+		for i, item := range list.Data {
+			if !drop[i] {
+				data = append(data, item)
+			}
+		}
+		return core.ListValue{Data: data}, nil
+	})
+	if missing {
+		return 0, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestListCommands(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	for _, v := range []string{"INT 1", "INT 2", "FLOAT 2", "INT 2", "INT 3"} {
+		mustRun(t, s, "PUSH l "+v)
+	}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"LLEN l", "5"},
+		{"LRANGE l -2 -1", "[2 3]"},
+		{"LRANGE l 3 100", "[2 3]"},
+		{"LINDEX l 2", "2"},
+		{"LREM l -1 INT 2", "1"},
+		{"PRINT l", "[1,2,2,3]"},
+		{"LSET l 0 STRING a", "OK"},
+		{"LPOP l", "a"},
+		{"RPOP l 2", "[3 2]"},
+		{"PRINT l", "[2]"},
+		{"LINDEX l 9", "<nil>"},
+		{"LPOP missing", "<nil>"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestListCommands_EmptiedListIsDeleted(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "PUSH l INT 1")
+	mustRun(t, s, "PUSH r INT 2")
+	mustRun(t, s, "PUSH r INT 2")
+	mustRun(t, s, "OBJECT u 0")
+	mustRun(t, s, "PUSH u.tags INT 3")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"LPOP l", "1"},
+		{"EXISTS l", "0"},
+		{"TYPE l", "none"},
+		{"LREM r 0 INT 2", "2"},
+		{"EXISTS r", "0"},
+		{"TYPE r", "none"},
+		{"RPOP u.tags", "3"},
+		{"PRINT u", "{tags:[]}"},
+		{"KEYS *", "[u]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestListCommands_PopDoesNotAffectEarlierReads(t *testing.T) {
+	tb := storage.NewTypeBox()
+	s := NewExecutor(tb).NewSession()
+	mustRun(t, s, "PUSH l INT 1")
+	mustRun(t, s, "PUSH l INT 2")
+
+	before, _ := tb.Get("l")
+	mustRun(t, s, "RPOP l")
+	mustRun(t, s, "PUSH l INT 9")
+	if got := core.FormatValue(before); got != "[1,2]" {
+		t.Fatalf("earlier read changed to %s", got)
+	}
+}
+
+func TestStrictPush(t *testing.T) {
+	exec := NewExecutor(storage.NewTypeBox())
+	exec.SetStrictPush(true)
+	s := exec.NewSession()
+
+	mustRun(t, s, "SET n INT 1")
+	if _, err := run(t, s, "PUSH n INT 2"); !errors.Is(err, core.ErrWrongKind) {
+		t.Fatalf("expected ErrWrongKind, got %v", err)
+	}
+	mustRun(t, s, "PUSH fresh INT 2")
+}
@@ -0,0 +1,92 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestMaxMemory_EvictsBeforeWrites(t *testing.T) {
+	tb := storage.NewShardedTypeBox(1)
+	s := NewExecutor(tb).NewSession()
+	mustRun(t, s, "SET a INT 1")
+	mustRun(t, s, "SET b INT 2")
+	tb.SetMaxMemory(tb.MemoryInfo().Used, storage.AllKeysLRU)
+
+	mustRun(t, s, "PRINT a")
+	mustRun(t, s, "SET c INT 3")
+	mustRun(t, s, "SET d INT 4")
+
+	if got := fmt.Sprint(mustRun(t, s, "EXISTS a b c d")); got != "3" {
+		t.Fatalf("EXISTS: got %s, want 3", got)
+	}
+	if got := fmt.Sprint(mustRun(t, s, "EXISTS b")); got != "0" {
+		t.Fatal("the least recently used key b should be gone")
+	}
+	info := mustRun(t, s, "INFO memory")
+	for _, want := range []string{"maxmemory_policy:allkeys-lru", "evicted_keys:1"} {
+		if !contains(info, want) {
+			t.Errorf("INFO lacks %q: %v", want, info)
+		}
+	}
+}
+
+func TestMaxMemory_NoEvictionRefusesGrowth(t *testing.T) {
+	tb := storage.NewTypeBox()
+	s := NewExecutor(tb).NewSession()
+	mustRun(t, s, "SET a INT 1")
+	mustRun(t, s, "SET b INT 2")
+	tb.SetMaxMemory(1, storage.NoEviction)
+
+	if _, err := run(t, s, "SET c INT 3"); !errors.Is(err, storage.ErrOutOfMemory) {
+		t.Fatalf("SET: got %v, want ErrOutOfMemory", err)
+	}
+	if got := fmt.Sprint(mustRun(t, s, "DEL a")); got != "1" {
+		t.Fatalf("DEL over the limit: got %s", got)
+	}
+}
+
+func contains(reply interface{}, line string) bool {
+	for _, item := range reply.([]interface{}) {
+		if item == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMaxMemory_LoadAndImportRespectLimit(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "dump.db")
+	data := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(data, []byte(`{"x": 1, "y": "two"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := storage.NewShardedTypeBox(1)
+	s := NewExecutor(tb).NewSession()
+	mustRun(t, s, "SET a INT 1")
+	mustRun(t, s, "SET b INT 2")
+	mustRun(t, s, "SAVE "+snapshot)
+	mustRun(t, s, "DEL b")
+	tb.SetMaxMemory(tb.MemoryInfo().Used, storage.NoEviction)
+
+	for _, cmd := range []string{"LOAD " + snapshot, "IMPORT " + data} {
+		if _, err := run(t, s, cmd); !errors.Is(err, storage.ErrOutOfMemory) {
+			t.Errorf("%s: got %v, want ErrOutOfMemory", cmd, err)
+		}
+	}
+	if got := fmt.Sprint(mustRun(t, s, "KEYS *")); got != "[a]" {
+		t.Fatalf("refused LOAD or IMPORT changed the store: %s", got)
+	}
+
+	tb.SetMaxMemory(tb.MemoryInfo().Used, storage.AllKeysLRU)
+	mustRun(t, s, "IMPORT "+data)
+	if mem := tb.MemoryInfo(); mem.Used > mem.Limit || mem.Evicted == 0 {
+		t.Fatalf("IMPORT under allkeys-lru: used %d, limit %d, evicted %d", mem.Used, mem.Limit, mem.Evicted)
+	}
+}
@@ -0,0 +1,89 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// cmdIndex handles "INDEX CREATE name ON field" and "INDEX DROP name".
+func cmdIndex(e *Executor, args []string) (interface{}, error) {
+	switch strings.ToUpper(args[1]) {
+	case "CREATE":
+		if len(args) != 5 || !strings.EqualFold(args[3], "ON") {
+			return nil, fmt.Errorf("%w: expected 'INDEX CREATE name ON field'", core.ErrParse)
+		}
+		if err := e.tb.CreateIndex(args[2], args[4]); err != nil {
+			return nil, err
+		}
+	case "DROP":
+		if len(args) != 3 {
+			return nil, arityError("INDEX DROP")
+		}
+		if err := e.tb.DropIndex(args[2]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown INDEX subcommand '%s'", core.ErrParse, args[1])
+	}
+	return OK, nil
+}
+
+func cmdIndexes(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, arityError("INDEXES")
+	}
+	var reply []interface{}
+	for _, info := range e.tb.Indexes() {
+		reply = append(reply, info.Name+" ON "+info.Field)
+	}
+	return reply, nil
+}
+
+// cmdFind handles "FIND field op value" and "FIND field IN (v1, v2, ...)"
+// and replies with the sorted keys of the matching objects.
+func cmdFind(e *Executor, args []string) (interface{}, error) {
+	cond, err := parseFindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	keys, _ := e.tb.Find(cond)
+	reply := make([]interface{}, len(keys))
+	for i, key := range keys {
+		reply[i] = key
+	}
+	return reply, nil
+}
+
+func parseFindArgs(args []string) (core.Condition, error) {
+	cond, rest, err := core.ParseCondition(args[1:])
+	if err != nil {
+		return core.Condition{}, err
+	}
+	if len(rest) != 0 {
+		return core.Condition{}, fmt.Errorf("%w: unexpected '%s' after condition", core.ErrParse, rest[0])
+	}
+	return cond, nil
+}
+
+// cmdExplain handles "EXPLAIN FIND ..." and "EXPLAIN SELECT ..." and
+// replies with the query plan without running the query.
+func cmdExplain(e *Executor, args []string) (interface{}, error) {
+	switch strings.ToUpper(args[1]) {
+	case "FIND":
+		cond, err := parseFindArgs(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return e.tb.Explain(cond).String(), nil
+	case "SELECT":
+		q, err := parseSelect(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return q.explain(e.tb), nil
+	default:
+		return nil, fmt.Errorf("%w: cannot EXPLAIN '%s'", core.ErrParse, args[1])
+	}
+}
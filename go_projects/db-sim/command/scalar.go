@@ -0,0 +1,132 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+var ErrOverflow = errors.New("increment would overflow")
+
+// cmdIncr handles INCR, INCRBY and INCRBYFLOAT on a key or path. A missing
+// value counts as 0. INT stays INT (overflow is an error) and FLOAT stays
+// FLOAT; INCRBYFLOAT always leaves a FLOAT. The read and the write happen
+// under one shard lock, so concurrent increments are never lost.
+func cmdIncr(e *Executor, args []string) (interface{}, error) {
+	name := strings.ToUpper(args[0])
+	var (
+		delta      int
+		floatDelta float64
+		err        error
+	)
+	switch name {
+	case "INCR":
+		if len(args) != 2 {
+			return nil, arityError(name)
+		}
+		delta = 1
+	case "INCRBY":
+		if len(args) != 3 {
+			return nil, arityError(name)
+		}
+		if delta, err = parseInt(args[2]); err != nil {
+			return nil, err
+		}
+	default:
+		if len(args) != 3 {
+			return nil, arityError(name)
+		}
+		floatDelta, err = strconv.ParseFloat(args[2], 64)
+		if err != nil || math.IsNaN(floatDelta) || math.IsInf(floatDelta, 0) {
+			return nil, fmt.Errorf("%w: invalid float '%s'", core.ErrParse, args[2])
+		}
+	}
+
+	var result interface{}
+	err = e.updateAt(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			old = 0
+			if name == "INCRBYFLOAT" {
+				old = 0.0
+			}
+		}
+		switch v := old.(type) {
+		case int:
+			if name == "INCRBYFLOAT" {
+				result = float64(v) + floatDelta
+			} else {
+				sum := v + delta
+				if (delta > 0 && sum < v) || (delta < 0 && sum > v) {
+					return nil, ErrOverflow
+				}
+				result = sum
+			}
+		case float64:
+			if name == "INCRBYFLOAT" {
+				result = v + floatDelta
+			} else {
+				result = v + float64(delta)
+			}
+			if math.IsInf(result.(float64), 0) {
+				return nil, ErrOverflow
+			}
+		default:
+			return nil, fmt.Errorf("%w: '%s' is not an INT or FLOAT", core.ErrWrongKind, args[1])
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := result.(int); ok {
+		return n, nil
+	}
+	return core.FormatValue(result), nil
+}
+
+// cmdAppend handles "APPEND key|path text" and replies with the new length
+// in characters. A missing value starts as the empty STRING.
+func cmdAppend(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("APPEND")
+	}
+	var length int
+	err := e.updateAt(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			old = ""
+		}
+		s, ok := old.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s' is not a STRING", core.ErrWrongKind, args[1])
+		}
+		s += args[2]
+		length = utf8.RuneCountInString(s)
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return length, nil
+}
+
+// cmdStrLen replies with the length of a STRING in characters, 0 when it
+// does not exist.
+func cmdStrLen(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("STRLEN")
+	}
+	val, exists, err := e.valueAt(args[1])
+	if err != nil || !exists {
+		return 0, err
+	}
+	s, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s' is not a STRING", core.ErrWrongKind, args[1])
+	}
+	return utf8.RuneCountInString(s), nil
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestIncr_KeepsType(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "SET f FLOAT 1.5")
+	mustRun(t, s, "OBJECT u 1 visits INT 9")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"INCR n", "1"},
+		{"INCRBY n -5", "-4"},
+		{"INCRBY f 2", "3.5"},
+		{"INCRBYFLOAT n 0.5", "-3.5"},
+		{"TYPE n", "scalar-float"},
+		{"INCR u.visits", "10"},
+		{"INCR u.fresh", "1"},
+		{"APPEND s héllo", "5"},
+		{"APPEND s !", "6"},
+		{"STRLEN s", "6"},
+		{"STRLEN nope", "0"},
+		{"PRINT u", "{fresh:1,visits:10}"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+
+	mustRun(t, s, "PUSH l INT 1")
+	for _, cmd := range []string{"INCR l", "INCR u", "INCR s", "APPEND n x", "STRLEN l", "APPEND u x"} {
+		if _, err := run(t, s, cmd); !errors.Is(err, core.ErrWrongKind) {
+			t.Errorf("%s: expected ErrWrongKind, got %v", cmd, err)
+		}
+	}
+
+	mustRun(t, s, "SET big INT "+strconv.Itoa(math.MaxInt))
+	if _, err := run(t, s, "INCR big"); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow, got %v", err)
+	}
+	if _, err := run(t, s, "INCRBY n 1.5"); !errors.Is(err, core.ErrParse) {
+		t.Fatalf("expected ErrParse, got %v", err)
+	}
+}
+
+func TestIncr_ConcurrentIncrementsAreNotLost(t *testing.T) {
+	e := NewExecutor(storage.NewTypeBox())
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				e.Exec([]string{"INCR", "hits"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := e.Exec([]string{"PRINT", "hits"}); got != "4000" {
+		t.Fatalf("got %v, want 4000", got)
+	}
+}
@@ -0,0 +1,327 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+// selectQuery is a parsed "SELECT fields FROM pattern [WHERE cond [AND
+// cond ...]] [ORDER BY field [ASC|DESC]] [LIMIT n [OFFSET m]] [FORMAT
+// TABLE|JSON]". A nil fields list means "*".
+type selectQuery struct {
+	fields  []string
+	paths   [][]core.PathSegment
+	pattern string
+	where   []core.Condition
+	orderBy []core.PathSegment
+	desc    bool
+	limit   int
+	offset  int
+	json    bool
+}
+
+type selectRow struct {
+	key string
+	obj core.ObjectValue
+}
+
+func parseSelect(args []string) (*selectQuery, error) {
+	q := &selectQuery{limit: -1}
+
+	i := 1
+	for ; i < len(args) && !strings.EqualFold(args[i], "FROM"); i++ {
+		for _, part := range strings.Split(args[i], ",") {
+			if part != "" {
+				q.fields = append(q.fields, part)
+			}
+		}
+	}
+	if len(q.fields) == 0 || i+1 >= len(args) {
+		return nil, fmt.Errorf("%w: expected 'SELECT fields FROM pattern'", core.ErrParse)
+	}
+	if len(q.fields) == 1 && q.fields[0] == "*" {
+		q.fields = nil
+	}
+	for _, field := range q.fields {
+		path, err := core.ParseFieldPath(field)
+		if err != nil {
+			return nil, err
+		}
+		q.paths = append(q.paths, path)
+	}
+	q.pattern = args[i+1]
+	rest := args[i+2:]
+
+	var err error
+	if q.where, rest, err = parseWhere(rest); err != nil {
+		return nil, err
+	}
+
+	if len(rest) >= 3 && strings.EqualFold(rest[0], "ORDER") && strings.EqualFold(rest[1], "BY") {
+		path, err := core.ParseFieldPath(rest[2])
+		if err != nil {
+			return nil, err
+		}
+		q.orderBy, rest = path, rest[3:]
+		if len(rest) > 0 && (strings.EqualFold(rest[0], "ASC") || strings.EqualFold(rest[0], "DESC")) {
+			q.desc, rest = strings.EqualFold(rest[0], "DESC"), rest[1:]
+		}
+	}
+
+	if len(rest) >= 2 && strings.EqualFold(rest[0], "LIMIT") {
+		n, err := strconv.Atoi(rest[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: invalid LIMIT '%s'", core.ErrParse, rest[1])
+		}
+		q.limit, rest = n, rest[2:]
+		if len(rest) >= 2 && strings.EqualFold(rest[0], "OFFSET") {
+			if q.offset, err = strconv.Atoi(rest[1]); err != nil || q.offset < 0 {
+				return nil, fmt.Errorf("%w: invalid OFFSET '%s'", core.ErrParse, rest[1])
+			}
+			rest = rest[2:]
+		}
+	}
+
+	if len(rest) >= 2 && strings.EqualFold(rest[0], "FORMAT") {
+		switch strings.ToUpper(rest[1]) {
+		case "JSON":
+			q.json = true
+		case "TABLE":
+		default:
+			return nil, fmt.Errorf("%w: unknown FORMAT '%s'", core.ErrParse, rest[1])
+		}
+		rest = rest[2:]
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: unexpected '%s' in SELECT", core.ErrParse, rest[0])
+	}
+	return q, nil
+}
+
+// parseWhere reads an optional "WHERE cond [AND cond ...]" clause.
+func parseWhere(args []string) ([]core.Condition, []string, error) {
+	if len(args) == 0 || !strings.EqualFold(args[0], "WHERE") {
+		return nil, args, nil
+	}
+	var where []core.Condition
+	rest := args[1:]
+	for {
+		cond, next, err := core.ParseCondition(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, cond)
+		rest = next
+		if len(rest) == 0 || !strings.EqualFold(rest[0], "AND") {
+			return where, rest, nil
+		}
+		rest = rest[1:]
+	}
+}
+
+// driver returns the WHERE condition an index can answer, if any, with the
+// plan Find would use for it.
+func (q *selectQuery) driver(tb *storage.TypeBox) (int, storage.Plan) {
+	for i, cond := range q.where {
+		if plan := tb.Explain(cond); plan.Index != "" {
+			return i, plan
+		}
+	}
+	return -1, storage.Plan{}
+}
+
+func (q *selectQuery) explain(tb *storage.TypeBox) string {
+	if i, plan := q.driver(tb); i >= 0 {
+		return fmt.Sprintf("%s, then filter keys by %s", plan, q.pattern)
+	}
+	return "full scan of keys matching " + q.pattern
+}
+
+func (q *selectQuery) matches(key string, val interface{}) (core.ObjectValue, bool) {
+	obj, ok := val.(core.ObjectValue)
+	if !ok || !core.MatchGlob(q.pattern, key) {
+		return obj, false
+	}
+	for _, cond := range q.where {
+		if !cond.Match(obj) {
+			return obj, false
+		}
+	}
+	return obj, true
+}
+
+func (q *selectQuery) rows(tb *storage.TypeBox) []selectRow {
+	var rows []selectRow
+	if i, _ := q.driver(tb); i >= 0 {
+		keys, _ := tb.Find(q.where[i])
+		for _, key := range keys {
+			val, _ := tb.Get(key)
+			if obj, ok := q.matches(key, val); ok {
+				rows = append(rows, selectRow{key, obj})
+			}
+		}
+	} else {
+		tb.Range(func(key string, val interface{}) bool {
+			if obj, ok := q.matches(key, val); ok {
+				rows = append(rows, selectRow{key, obj})
+			}
+			return true
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if q.orderBy != nil {
+			a, aok := core.FieldValue(rows[i].obj, q.orderBy)
+			b, bok := core.FieldValue(rows[j].obj, q.orderBy)
+			if aok != bok {
+				return aok
+			}
+			if c := compareValues(a, b); aok && c != 0 {
+				return c < 0 != q.desc
+			}
+		}
+		return rows[i].key < rows[j].key
+	})
+
+	if q.offset >= len(rows) {
+		return nil
+	}
+	rows = rows[q.offset:]
+	if q.limit >= 0 && q.limit < len(rows) {
+		rows = rows[:q.limit]
+	}
+	return rows
+}
+
+// compareValues orders numbers numerically, before everything else, which
+// is ordered by its formatted text. Rows missing the ORDER BY field always
+// come last.
+func compareValues(a, b interface{}) int {
+	an, aNum := core.Numeric(a)
+	bn, bNum := core.Numeric(b)
+	switch {
+	case aNum && bNum:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(core.FormatValue(a), core.FormatValue(b))
+}
+
+// columns returns the projected field names; "*" is every field any row has.
+func (q *selectQuery) columns(rows []selectRow) ([]string, [][]core.PathSegment) {
+	if q.fields != nil {
+		return q.fields, q.paths
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, row := range rows {
+		for name := range row.obj.Data {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	paths := make([][]core.PathSegment, len(names))
+	for i, name := range names {
+		paths[i] = []core.PathSegment{{Field: name}}
+	}
+	return names, paths
+}
+
+// cmdSelect replies with a table, one line per array element, or with a
+// JSON array of objects; both carry each row's key in a "key" column.
+func cmdSelect(e *Executor, args []string) (interface{}, error) {
+	q, err := parseSelect(args)
+	if err != nil {
+		return nil, err
+	}
+	rows := q.rows(e.tb)
+	names, paths := q.columns(rows)
+
+	if q.json {
+		list := core.ListValue{Data: make([]interface{}, len(rows))}
+		for i, row := range rows {
+			obj := core.NewObjectValue()
+			obj.Data["key"] = row.key
+			for j, name := range names {
+				if v, ok := core.FieldValue(row.obj, paths[j]); ok {
+					obj.Data[name] = v
+				} else {
+					obj.Data[name] = core.NullValue{}
+				}
+			}
+			list.Data[i] = obj
+		}
+		data, err := core.ToJSON(list)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+
+	table := [][]string{append([]string{"key"}, names...)}
+	for _, row := range rows {
+		line := []string{row.key}
+		for _, path := range paths {
+			if v, ok := core.FieldValue(row.obj, path); ok {
+				line = append(line, core.FormatValue(v))
+			} else {
+				line = append(line, "null")
+			}
+		}
+		table = append(table, line)
+	}
+	return formatTable(table), nil
+}
+
+// formatTable pads the cells into aligned columns and puts a rule under the
+// header row.
+func formatTable(table [][]string) []interface{} {
+	widths := make([]int, len(table[0]))
+	for _, line := range table {
+		for i, cell := range line {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+
+	render := func(cells []string) string {
+		var sb strings.Builder
+		for i, cell := range cells {
+			if i > 0 {
+				sb.WriteString(" | ")
+			}
+			sb.WriteString(cell)
+			if i < len(cells)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-len([]rune(cell))))
+			}
+		}
+		return sb.String()
+	}
+
+	rule := make([]string, len(widths))
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+	out := []interface{}{render(table[0]), strings.ReplaceAll(render(rule), " | ", "-+-")}
+	for _, line := range table[1:] {
+		out = append(out, render(line))
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func selectFixture(t *testing.T) *Session {
+	t.Helper()
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "OBJECT user:1 3 name STRING Ann age INT 17 city STRING Paris")
+	mustRun(t, s, "OBJECT user:2 3 name STRING Bob age INT 30 city STRING Rome")
+	mustRun(t, s, "OBJECT user:3 3 name STRING Cy age FLOAT 18.5 city STRING Rome")
+	mustRun(t, s, "OBJECT user:4 1 name STRING Dee")
+	mustRun(t, s, "OBJECT admin:1 2 name STRING Root age INT 99")
+	mustRun(t, s, "SET user:count INT 4")
+	return s
+}
+
+func TestSelect_TableWithFilterSortAndLimit(t *testing.T) {
+	s := selectFixture(t)
+
+	got := mustRun(t, s, "SELECT name, age FROM user:* WHERE age >= 18 ORDER BY age DESC LIMIT 10")
+	want := []interface{}{
+		"key    | name | age",
+		"-------+------+-----",
+		"user:2 | Bob  | 30",
+		"user:3 | Cy   | 18.5",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %q", got)
+	}
+
+	cases := map[string]string{
+		"SELECT name FROM user:* ORDER BY age LIMIT 2 OFFSET 1 FORMAT JSON":            `[{"key":"user:3","name":"Cy"},{"key":"user:2","name":"Bob"}]`,
+		"SELECT name FROM user:* ORDER BY age DESC FORMAT JSON":                        `[{"key":"user:2","name":"Bob"},{"key":"user:3","name":"Cy"},{"key":"user:1","name":"Ann"},{"key":"user:4","name":"Dee"}]`,
+		"SELECT * FROM user:* WHERE city = Rome AND age < 20 FORMAT JSON":              `[{"age":18.5,"city":"Rome","key":"user:3","name":"Cy"}]`,
+		"SELECT name, city FROM user:* WHERE name IN (Dee, Root) FORMAT JSON":          `[{"city":null,"key":"user:4","name":"Dee"}]`,
+		"SELECT name FROM nobody:* FORMAT JSON":                                        `[]`,
+		"EXPLAIN SELECT name FROM user:* WHERE age > 1":                                "full scan of keys matching user:*",
+		"SELECT name,age FROM user:* WHERE age > 20 ORDER BY name LIMIT 0 FORMAT JSON": `[]`,
+	}
+	for cmd, want := range cases {
+		if got := fmt.Sprint(mustRun(t, s, cmd)); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", cmd, got, want)
+		}
+	}
+}
+
+func TestSelect_IndexGivesSameRows(t *testing.T) {
+	s := selectFixture(t)
+	query := "SELECT name FROM user:* WHERE city = Rome AND age > 18 ORDER BY name FORMAT JSON"
+	before := mustRun(t, s, query)
+
+	mustRun(t, s, "INDEX CREATE by_age ON age")
+	if got := mustRun(t, s, "EXPLAIN "+query); !strings.HasPrefix(fmt.Sprint(got), "index range scan on by_age") {
+		t.Fatalf("index not used: %v", got)
+	}
+	if after := mustRun(t, s, query); after != before {
+		t.Fatalf("index changed the result: %v vs %v", after, before)
+	}
+}
+
+func TestSelect_SyntaxErrors(t *testing.T) {
+	s := selectFixture(t)
+	for _, cmd := range []string{
+		"SELECT FROM user:*",
+		"SELECT name user:*",
+		"SELECT name FROM user:* WHERE age",
+		"SELECT name FROM user:* LIMIT -1",
+		"SELECT name FROM user:* FORMAT XML",
+		"SELECT name FROM user:* GROUP BY name",
+	} {
+		if _, err := run(t, s, cmd); !errors.Is(err, core.ErrParse) && !errors.Is(err, core.ErrWrongArity) {
+			t.Errorf("%s: expected a parse error, got %v", cmd, err)
+		}
+	}
+}
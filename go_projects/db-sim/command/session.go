@@ -0,0 +1,212 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dim4d/DbSim/storage"
+)
+
+var ErrExecAbort = errors.New("transaction aborted")
+
+const Queued Status = "QUEUED"
+
+// Session holds the per-client state of MULTI/EXEC transactions: the queued
+// commands and the key versions recorded by WATCH.
+type Session struct {
+	e        *Executor
+	inMulti  bool
+	queue    [][]string
+	queueErr error
+	watched  map[string]uint64
+}
+
+func (e *Executor) NewSession() *Session {
+	return &Session{e: e}
+}
+
+func (s *Session) Exec(args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return s.e.Exec(args)
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "MULTI":
+		if s.inMulti {
+			return nil, errors.New("MULTI calls can not be nested")
+		}
+		s.inMulti = true
+		return OK, nil
+	case "EXEC":
+		if !s.inMulti {
+			return nil, errors.New("EXEC without MULTI")
+		}
+		return s.execQueued()
+	case "DISCARD":
+		if !s.inMulti {
+			return nil, errors.New("DISCARD without MULTI")
+		}
+		s.reset()
+		return OK, nil
+	case "WATCH":
+		if s.inMulti {
+			return nil, errors.New("WATCH inside MULTI is not allowed")
+		}
+		if len(args) < 2 {
+			return nil, arityError("WATCH")
+		}
+		s.watch(args[1:])
+		return OK, nil
+	case "UNWATCH":
+		s.watched = nil
+		return OK, nil
+	}
+
+	if !s.inMulti {
+		return s.e.Exec(args)
+	}
+
+	sp, err := lookup(args)
+	if err == nil && sp.noMulti {
+		err = fmt.Errorf("'%s' is not allowed inside MULTI", strings.ToUpper(args[0]))
+	}
+	if err != nil {
+		if s.queueErr == nil {
+			s.queueErr = err
+		}
+		return nil, err
+	}
+	s.queue = append(s.queue, args)
+	return Queued, nil
+}
+
+func (s *Session) watch(keys []string) {
+	s.e.mu.RLock()
+	defer s.e.mu.RUnlock()
+
+	if s.watched == nil {
+		s.watched = make(map[string]uint64)
+	}
+	for _, key := range keys {
+		if _, ok := s.watched[key]; !ok {
+			s.watched[key] = s.e.tb.Version(key)
+		}
+	}
+}
+
+func (s *Session) reset() {
+	s.inMulti = false
+	s.queue = nil
+	s.queueErr = nil
+	s.watched = nil
+}
+
+// execQueued runs the queued commands under the executor lock. A watched key
+// that changed since WATCH aborts with a nil reply; a failing command rolls
+// back every key the transaction already wrote and nothing is logged, as
+// does a failure to append the transaction to the log. The keyspace events
+// of the written keys are held until the outcome is known, so a rolled back
+// transaction publishes nothing.
+func (s *Session) execQueued() (interface{}, error) {
+	queue, queueErr, watched := s.queue, s.queueErr, s.watched
+	s.reset()
+
+	if queueErr != nil {
+		return nil, fmt.Errorf("%w: discarded because of previous errors", ErrExecAbort)
+	}
+	if err := s.e.tb.Err(); err != nil {
+		return nil, err
+	}
+
+	e := s.e
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, version := range watched {
+		if e.tb.Version(key) != version {
+			return nil, nil
+		}
+	}
+
+	var evicted [][]string
+	for _, args := range queue {
+		if sp, _ := lookup(args); sp.write && !sp.shrinks {
+			var err error
+			if evicted, err = e.reclaim(); err != nil {
+				e.log(evicted)
+				return nil, fmt.Errorf("%w: %v", ErrExecAbort, err)
+			}
+			break
+		}
+	}
+
+	var held []string
+	for _, args := range queue {
+		if sp, _ := lookup(args); sp.write {
+			held = append(held, sp.keys(args)...)
+		}
+	}
+	e.tb.HoldEvents(held)
+
+	undo := make(undoLog)
+	replies := make([]interface{}, 0, len(queue))
+	logged := evicted
+	var changes []pendingChange
+	for _, args := range queue {
+		sp, _ := lookup(args)
+		if sp.write {
+			for _, key := range sp.keys(args) {
+				undo.save(e.tb, key)
+			}
+		}
+		before := e.versionsBefore(sp, args)
+		reply, logArgs, err := e.run(sp, args)
+		if err != nil {
+			undo.restore(e.tb)
+			e.tb.ReleaseEvents(false)
+			e.log(evicted)
+			return nil, fmt.Errorf("%w: %s: %v", ErrExecAbort, strings.ToUpper(args[0]), err)
+		}
+		if logArgs != nil {
+			cmds, err := e.logForm(sp, logArgs)
+			if err != nil {
+				undo.restore(e.tb)
+				e.tb.ReleaseEvents(false)
+				e.log(evicted)
+				return nil, fmt.Errorf("%w: %v", ErrExecAbort, err)
+			}
+			logged = append(logged, cmds...)
+			changes = append(changes, e.changesSince(sp, logArgs, before)...)
+		}
+		replies = append(replies, reply)
+	}
+
+	if err := e.log(logged); err != nil {
+		undo.restore(e.tb)
+		e.tb.ReleaseEvents(false)
+		return nil, fmt.Errorf("%w: %v", ErrExecAbort, err)
+	}
+	e.tb.ReleaseEvents(true)
+	e.record(changes)
+	if err := e.tb.Err(); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// undoLog keeps the state keys had before a write. Stored values are never
+// changed in place, so holding on to them is enough.
+type undoLog map[string]storage.KeyState
+
+func (u undoLog) save(tb *storage.TypeBox, key string) {
+	if _, ok := u[key]; !ok {
+		u[key] = tb.Save(key)
+	}
+}
+
+func (u undoLog) restore(tb *storage.TypeBox) {
+	for key, st := range u {
+		tb.Restore(key, st)
+	}
+}
@@ -0,0 +1,204 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/persist"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func run(t *testing.T, s *Session, line string) (interface{}, error) {
+	t.Helper()
+	return s.Exec(strings.Fields(line))
+}
+
+func mustRun(t *testing.T, s *Session, line string) interface{} {
+	t.Helper()
+	reply, err := run(t, s, line)
+	if err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return reply
+}
+
+func openWithAOF(t *testing.T, path string) (*Session, *persist.AOF) {
+	t.Helper()
+	e := NewExecutor(storage.NewTypeBox())
+	aof, err := persist.OpenAOF(path, persist.FsyncAlways, func(args []string) error {
+		_, err := e.Exec(args)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	e.SetAOF(aof)
+	return e.NewSession(), aof
+}
+
+func TestSession_ExecRollsBackOnError(t *testing.T) {
+	tb := storage.NewTypeBox()
+	s := NewExecutor(tb).NewSession()
+
+	mustRun(t, s, "OBJECT o 1 x INT 1")
+	mustRun(t, s, "OBJECT p 1 y INT 2")
+	mustRun(t, s, "SET n INT 1")
+
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "MERGE o p")
+	mustRun(t, s, "PUSH fresh INT 1")
+	mustRun(t, s, "MERGE o n")
+	if _, err := run(t, s, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Fatalf("expected ErrExecAbort, got %v", err)
+	}
+
+	if v, _ := tb.Get("o"); core.FormatValue(v) != "{x:1}" {
+		t.Fatalf("o was modified: %s", core.FormatValue(v))
+	}
+	if _, exists := tb.Get("fresh"); exists {
+		t.Fatal("fresh should not exist after rollback")
+	}
+}
+
+func TestSession_RollbackPublishesNoEvents(t *testing.T) {
+	e := NewExecutor(storage.NewTypeBox())
+	s := e.NewSession()
+	mustRun(t, s, "SET n INT 1")
+	sub := e.Subscribe(0)
+	defer sub.Close()
+	sub.PSubscribe(storage.KeyeventPrefix + "*")
+
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "SET n INT 2")
+	mustRun(t, s, "PUSH fresh INT 1")
+	mustRun(t, s, "LPOP n")
+	if _, err := run(t, s, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Fatalf("expected ErrExecAbort, got %v", err)
+	}
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "SET n INT 3")
+	mustRun(t, s, "EXEC")
+
+	// The committed SET must be the first message: nothing from the aborted
+	// transaction or its rollback comes before it.
+	select {
+	case msg := <-sub.C():
+		if msg.Channel != storage.KeyeventPrefix+"set" || msg.Payload != "n" {
+			t.Fatalf("first message %+v, want the committed set of n", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message for the committed transaction")
+	}
+	select {
+	case msg := <-sub.C():
+		t.Fatalf("unexpected message %+v", msg)
+	default:
+	}
+}
+
+func TestSession_QueueErrorDiscardsExec(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "SET a INT 1")
+	if _, err := run(t, s, "SET a"); err == nil {
+		t.Fatal("expected arity error while queueing")
+	}
+	if _, err := run(t, s, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Fatalf("expected ErrExecAbort, got %v", err)
+	}
+	if reply := mustRun(t, s, "PRINT a"); reply != nil {
+		t.Fatalf("a should not be set, got %v", reply)
+	}
+}
+
+func TestSession_WatchAbortsOnConcurrentWrite(t *testing.T) {
+	exec := NewExecutor(storage.NewTypeBox())
+	s1, s2 := exec.NewSession(), exec.NewSession()
+
+	mustRun(t, s1, "SET counter INT 1")
+	mustRun(t, s1, "WATCH counter")
+	mustRun(t, s2, "SET counter INT 5")
+
+	mustRun(t, s1, "MULTI")
+	mustRun(t, s1, "SET counter INT 2")
+	if reply := mustRun(t, s1, "EXEC"); reply != nil {
+		t.Fatalf("expected nil reply for aborted EXEC, got %v", reply)
+	}
+	if reply := mustRun(t, s1, "PRINT counter"); reply != "5" {
+		t.Fatalf("counter: got %v", reply)
+	}
+
+	mustRun(t, s1, "WATCH counter")
+	mustRun(t, s1, "MULTI")
+	mustRun(t, s1, "SET counter INT 2")
+	if reply := mustRun(t, s1, "EXEC"); len(reply.([]interface{})) != 1 {
+		t.Fatalf("unexpected EXEC reply %v", reply)
+	}
+}
+
+func TestSession_RollbackDoesNotAbortWatchers(t *testing.T) {
+	exec := NewExecutor(storage.NewTypeBox())
+	s1, s2 := exec.NewSession(), exec.NewSession()
+
+	mustRun(t, s1, "SET counter INT 1")
+	mustRun(t, s1, "WATCH counter other")
+	mustRun(t, s2, "MULTI")
+	mustRun(t, s2, "SET counter INT 9")
+	mustRun(t, s2, "SET other INT 9")
+	mustRun(t, s2, "LPOP counter")
+	if _, err := run(t, s2, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Fatalf("EXEC: got %v", err)
+	}
+
+	mustRun(t, s1, "MULTI")
+	mustRun(t, s1, "SET counter INT 2")
+	if reply := mustRun(t, s1, "EXEC"); reply == nil {
+		t.Fatal("EXEC aborted by a transaction that was rolled back")
+	}
+}
+
+func TestExec_FailedAppendLeavesStoreUnchanged(t *testing.T) {
+	s, aof := openWithAOF(t, filepath.Join(t.TempDir(), "db.aof"))
+	mustRun(t, s, "SET a INT 1")
+	mustRun(t, s, "INDEX CREATE ix ON f")
+	sub := s.e.Subscribe(0)
+	defer sub.Close()
+	sub.PSubscribe(storage.KeyeventPrefix + "*")
+	aof.Close()
+
+	for _, cmd := range []string{"SET a INT 2", "PUSH l INT 1", "INDEX DROP ix"} {
+		if _, err := run(t, s, cmd); err == nil {
+			t.Errorf("%s: expected the append to fail", cmd)
+		}
+	}
+	mustRun(t, s, "MULTI")
+	mustRun(t, s, "SET a INT 3")
+	if _, err := run(t, s, "EXEC"); !errors.Is(err, ErrExecAbort) {
+		t.Errorf("EXEC: got %v, want ErrExecAbort", err)
+	}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"PRINT a", "1"},
+		{"EXISTS l", "0"},
+		{"INDEXES", "[ix ON f]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+	select {
+	case msg := <-sub.C():
+		t.Errorf("unexpected message %+v", msg)
+	default:
+	}
+}
@@ -0,0 +1,418 @@
+package command
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// Sets and sorted sets live only at top-level keys. A key whose last member
+// is removed is deleted, so no empty set is ever stored.
+
+func asSet(key string, val interface{}) (core.SetValue, error) {
+	set, ok := val.(core.SetValue)
+	if !ok {
+		return core.SetValue{}, fmt.Errorf("%w: '%s' is not a set", core.ErrWrongKind, key)
+	}
+	return set, nil
+}
+
+func asSortedSet(key string, val interface{}) (core.SortedSetValue, error) {
+	zset, ok := val.(core.SortedSetValue)
+	if !ok {
+		return core.SortedSetValue{}, fmt.Errorf("%w: '%s' is not a sorted set", core.ErrWrongKind, key)
+	}
+	return zset, nil
+}
+
+// setAt reads the set at key; a missing key is an empty set.
+func (e *Executor) setAt(key string) (core.SetValue, error) {
+	val, exists := e.tb.Get(key)
+	if !exists {
+		return core.SetValue{}, nil
+	}
+	return asSet(key, val)
+}
+
+func (e *Executor) sortedSetAt(key string) (core.SortedSetValue, error) {
+	val, exists := e.tb.Get(key)
+	if !exists {
+		return core.SortedSetValue{}, nil
+	}
+	return asSortedSet(key, val)
+}
+
+func members(list []string) []interface{} {
+	out := make([]interface{}, len(list))
+	for i, m := range list {
+		out[i] = m
+	}
+	return out
+}
+
+// cmdSAdd handles "SADD key member [member ...]" and replies with the number
+// of members that were new.
+func cmdSAdd(e *Executor, args []string) (interface{}, error) {
+	if err := core.CheckKey(args[1]); err != nil {
+		return nil, err
+	}
+	added := 0
+	err := e.tb.Update(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		var set core.SetValue
+		if exists {
+			var err error
+			if set, err = asSet(args[1], old); err != nil {
+				return nil, err
+			}
+		}
+		for _, m := range args[2:] {
+			var isNew bool
+			if set, isNew = set.Add(m); isNew {
+				added++
+			}
+		}
+		return set, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// cmdSRem handles "SREM key member [member ...]" and replies with the number
+// of members removed.
+func cmdSRem(e *Executor, args []string) (interface{}, error) {
+	removed := 0
+	err := e.tb.UpdateOrDelete(args[1], func(old interface{}, exists bool) (interface{}, bool, error) {
+		if !exists {
+			return nil, false, nil
+		}
+		set, err := asSet(args[1], old)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, m := range args[2:] {
+			var gone bool
+			if set, gone = set.Remove(m); gone {
+				removed++
+			}
+		}
+		return set, set.Len() > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func cmdSIsMember(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("SISMEMBER")
+	}
+	set, err := e.setAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if set.Has(args[2]) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func cmdSMembers(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("SMEMBERS")
+	}
+	set, err := e.setAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return members(set.Members()), nil
+}
+
+func cmdSCard(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("SCARD")
+	}
+	set, err := e.setAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return set.Len(), nil
+}
+
+// cmdSetAlgebra handles SINTER, SUNION and SDIFF over one or more keys,
+// treating missing keys as empty sets. SDIFF keeps the members of the first
+// set found in none of the others. The reply is sorted.
+func cmdSetAlgebra(e *Executor, args []string) (interface{}, error) {
+	name := strings.ToUpper(args[0])
+	sets := make([]core.SetValue, len(args)-1)
+	for i, key := range args[1:] {
+		set, err := e.setAt(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	result := sets[0]
+	for _, other := range sets[1:] {
+		switch name {
+		case "SUNION":
+			for _, m := range other.Members() {
+				result, _ = result.Add(m)
+			}
+		case "SINTER":
+			for _, m := range result.Members() {
+				if !other.Has(m) {
+					result, _ = result.Remove(m)
+				}
+			}
+		default:
+			for _, m := range other.Members() {
+				result, _ = result.Remove(m)
+			}
+		}
+	}
+	return members(result.Members()), nil
+}
+
+func parseScore(raw string) (float64, error) {
+	score, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, fmt.Errorf("%w: invalid score '%s'", core.ErrParse, raw)
+	}
+	return score, nil
+}
+
+// parseScoreBound reads one end of a ZRANGEBYSCORE range: a number, -inf or
+// +inf, with a leading "(" to leave the score itself out.
+func parseScoreBound(raw string) (core.ScoreBound, error) {
+	var b core.ScoreBound
+	text := raw
+	if strings.HasPrefix(text, "(") {
+		b.Exclusive = true
+		text = text[1:]
+	}
+	switch strings.ToLower(text) {
+	case "-inf":
+		b.Score = math.Inf(-1)
+	case "+inf", "inf":
+		b.Score = math.Inf(1)
+	default:
+		score, err := parseScore(text)
+		if err != nil {
+			return b, fmt.Errorf("%w: invalid score bound '%s'", core.ErrParse, raw)
+		}
+		b.Score = score
+	}
+	return b, nil
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func zEntries(entries []core.ZEntry, withScores bool) []interface{} {
+	out := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Member)
+		if withScores {
+			out = append(out, formatScore(e.Score))
+		}
+	}
+	return out
+}
+
+// cmdZAdd handles "ZADD key score member [score member ...]". An existing
+// member moves to its new score; the reply counts the members that were new.
+func cmdZAdd(e *Executor, args []string) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, arityError("ZADD")
+	}
+	entries := make([]core.ZEntry, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		score, err := parseScore(args[i])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, core.ZEntry{Member: args[i+1], Score: score})
+	}
+
+	if err := core.CheckKey(args[1]); err != nil {
+		return nil, err
+	}
+	added := 0
+	err := e.tb.Update(args[1], func(old interface{}, exists bool) (interface{}, error) {
+		var zset core.SortedSetValue
+		if exists {
+			var err error
+			if zset, err = asSortedSet(args[1], old); err != nil {
+				return nil, err
+			}
+		}
+		for _, entry := range entries {
+			var isNew bool
+			if zset, isNew = zset.Add(entry.Member, entry.Score); isNew {
+				added++
+			}
+		}
+		return zset, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+func cmdZRem(e *Executor, args []string) (interface{}, error) {
+	removed := 0
+	err := e.tb.UpdateOrDelete(args[1], func(old interface{}, exists bool) (interface{}, bool, error) {
+		if !exists {
+			return nil, false, nil
+		}
+		zset, err := asSortedSet(args[1], old)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, m := range args[2:] {
+			var gone bool
+			if zset, gone = zset.Remove(m); gone {
+				removed++
+			}
+		}
+		return zset, zset.Len() > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func cmdZScore(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("ZSCORE")
+	}
+	zset, err := e.sortedSetAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	score, ok := zset.Score(args[2])
+	if !ok {
+		return nil, nil
+	}
+	return formatScore(score), nil
+}
+
+func cmdZCard(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, arityError("ZCARD")
+	}
+	zset, err := e.sortedSetAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return zset.Len(), nil
+}
+
+// cmdZRank replies with the 0-based position of a member in score order, or
+// nil when it is not in the set.
+func cmdZRank(e *Executor, args []string) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, arityError("ZRANK")
+	}
+	zset, err := e.sortedSetAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	rank, ok := zset.Rank(args[2])
+	if !ok {
+		return nil, nil
+	}
+	return rank, nil
+}
+
+// cmdZRange handles "ZRANGE key start stop [WITHSCORES]" with inclusive ranks
+// that count from the end when negative, as LRANGE does.
+func cmdZRange(e *Executor, args []string) (interface{}, error) {
+	withScores := false
+	if len(args) == 5 && strings.EqualFold(args[4], "WITHSCORES") {
+		withScores = true
+	} else if len(args) != 4 {
+		return nil, arityError("ZRANGE")
+	}
+	start, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInt(args[3])
+	if err != nil {
+		return nil, err
+	}
+	zset, err := e.sortedSetAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	n := zset.Len()
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	return zEntries(zset.Range(start, stop), withScores), nil
+}
+
+// cmdZRangeByScore handles
+// "ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]".
+func cmdZRangeByScore(e *Executor, args []string) (interface{}, error) {
+	lo, err := parseScoreBound(args[2])
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parseScoreBound(args[3])
+	if err != nil {
+		return nil, err
+	}
+	withScores := false
+	offset, count := 0, -1
+	for rest := args[4:]; len(rest) > 0; {
+		switch strings.ToUpper(rest[0]) {
+		case "WITHSCORES":
+			withScores = true
+			rest = rest[1:]
+		case "LIMIT":
+			if len(rest) < 3 {
+				return nil, arityError("ZRANGEBYSCORE")
+			}
+			if offset, err = parseInt(rest[1]); err != nil {
+				return nil, err
+			}
+			if count, err = parseInt(rest[2]); err != nil {
+				return nil, err
+			}
+			if offset < 0 {
+				return nil, fmt.Errorf("%w: offset must not be negative", core.ErrParse)
+			}
+			rest = rest[3:]
+		default:
+			return nil, fmt.Errorf("%w: unexpected '%s'", core.ErrParse, rest[0])
+		}
+	}
+
+	zset, err := e.sortedSetAt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	entries := zset.RangeByScore(lo, hi)
+	entries = entries[min(offset, len(entries)):]
+	if count >= 0 && count < len(entries) {
+		entries = entries[:count]
+	}
+	return zEntries(entries, withScores), nil
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestSetCommands(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "SADD a x y z")
+	mustRun(t, s, "SADD b y z w")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"SADD a x v", "1"},
+		{"SISMEMBER a v", "1"},
+		{"SISMEMBER a q", "0"},
+		{"SCARD a", "4"},
+		{"SMEMBERS a", "[v x y z]"},
+		{"SINTER a b", "[y z]"},
+		{"SUNION a b", "[v w x y z]"},
+		{"SDIFF a b", "[v x]"},
+		{"SINTER a missing", "[]"},
+		{"SREM a v x q", "2"},
+		{"PRINT a", "#{y,z}"},
+		{"TYPE a", "set"},
+		{"SREM a y z", "2"},
+		{"EXISTS a", "0"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestSortedSetCommands(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "ZADD z 3 c 1 a 2 b 2.5 bb")
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"ZADD z 0 c 4 d", "1"},
+		{"ZRANGE z 0 -1", "[c a b bb d]"},
+		{"ZRANGE z 1 2 WITHSCORES", "[a 1 b 2]"},
+		{"ZRANK z bb", "3"},
+		{"ZRANK z nope", "<nil>"},
+		{"ZSCORE z bb", "2.5"},
+		{"ZRANGEBYSCORE z 1 2.5", "[a b bb]"},
+		{"ZRANGEBYSCORE z (1 +inf LIMIT 1 2", "[bb d]"},
+		{"ZRANGEBYSCORE z -inf (1 WITHSCORES", "[c 0]"},
+		{"ZCARD z", "5"},
+		{"ZREM z a c", "2"},
+		{"PRINT z", "#[b:2,bb:2.5,d:4]"},
+		{"PRINT z JSON", `{"b":2.0,"bb":2.5,"d":4.0}`},
+		{"TYPE z", "zset"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(mustRun(t, s, c.cmd)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.cmd, got, c.want)
+		}
+	}
+}
+
+func TestSetCommands_WrongKind(t *testing.T) {
+	s := NewExecutor(storage.NewTypeBox()).NewSession()
+	mustRun(t, s, "SET n INT 1")
+	mustRun(t, s, "SADD s x")
+
+	for _, cmd := range []string{"SADD n x", "SINTER s n", "ZADD s 1 x", "ZRANGE s 0 -1"} {
+		if _, err := run(t, s, cmd); !errors.Is(err, core.ErrWrongKind) {
+			t.Errorf("%s: got %v, want ErrWrongKind", cmd, err)
+		}
+	}
+	if _, err := run(t, s, "ZADD z nan x"); !errors.Is(err, core.ErrParse) {
+		t.Errorf("nan score: got %v, want ErrParse", err)
+	}
+}
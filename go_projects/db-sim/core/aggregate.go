@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+type AggFunc string
+
+const (
+	AggSum   AggFunc = "SUM"
+	AggAvg   AggFunc = "AVG"
+	AggMin   AggFunc = "MIN"
+	AggMax   AggFunc = "MAX"
+	AggCount AggFunc = "COUNT"
+)
+
+func ParseAggFunc(s string) (AggFunc, error) {
+	switch fn := AggFunc(strings.ToUpper(s)); fn {
+	case AggSum, AggAvg, AggMin, AggMax, AggCount:
+		return fn, nil
+	default:
+		return "", fmt.Errorf("%w: unknown aggregate '%s'", ErrParse, s)
+	}
+}
+
+// decimalAvgPlaces is how many digits past the scale of the sum an AVG of
+// DECIMALs keeps when the division does not come out even.
+const decimalAvgPlaces = 6
+
+// Aggregator folds values into one aggregate. SUM stays an INT while every
+// input is an INT and fits, and a DECIMAL while every input is a DECIMAL, and
+// becomes a FLOAT otherwise; AVG is a DECIMAL over DECIMALs and a FLOAT over
+// anything else; MIN and MAX return the winning input with its own type,
+// compared exactly when every input is a DECIMAL; COUNT counts every value,
+// numeric or not. For the other functions a non-numeric value is skipped, or
+// with strict set rejected with ErrWrongKind.
+type Aggregator struct {
+	fn         AggFunc
+	strict     bool
+	count      int
+	allInt     bool
+	intSum     int
+	allDecimal bool
+	decimalSum Decimal
+	floatSum   float64
+	best       interface{}
+	bestNum    float64
+}
+
+func NewAggregator(fn AggFunc, strict bool) *Aggregator {
+	return &Aggregator{fn: fn, strict: strict, allInt: true, allDecimal: true}
+}
+
+func (a *Aggregator) Add(v interface{}) error {
+	if a.fn == AggCount {
+		a.count++
+		return nil
+	}
+
+	n, ok := Numeric(v)
+	if !ok {
+		if a.strict {
+			return fmt.Errorf("%w: %s over non-numeric value '%s'", ErrWrongKind, a.fn, FormatValue(v))
+		}
+		return nil
+	}
+
+	a.count++
+	a.floatSum += n
+	if i, isInt := v.(int); isInt && a.allInt {
+		sum := a.intSum + i
+		if (i > 0 && sum < a.intSum) || (i < 0 && sum > a.intSum) {
+			a.allInt = false
+		}
+		a.intSum = sum
+	} else {
+		a.allInt = false
+	}
+	d, isDecimal := v.(Decimal)
+	if isDecimal && a.allDecimal {
+		a.decimalSum = a.decimalSum.Add(d)
+	} else {
+		a.allDecimal = false
+	}
+
+	better := a.best == nil
+	if !better && a.allDecimal {
+		c := d.Cmp(a.best.(Decimal))
+		better = a.fn == AggMin && c < 0 || a.fn == AggMax && c > 0
+	} else if !better {
+		better = a.fn == AggMin && n < a.bestNum || a.fn == AggMax && n > a.bestNum
+	}
+	if better {
+		a.best, a.bestNum = v, n
+	}
+	return nil
+}
+
+// Result returns the aggregate: 0 for SUM and COUNT of nothing, nil for the
+// others.
+func (a *Aggregator) Result() interface{} {
+	switch a.fn {
+	case AggCount:
+		return a.count
+	case AggSum:
+		if a.allInt {
+			return a.intSum
+		}
+		if a.allDecimal {
+			return a.decimalSum
+		}
+		return a.floatSum
+	case AggAvg:
+		if a.count == 0 {
+			return nil
+		}
+		if a.allInt {
+			return float64(a.intSum) / float64(a.count)
+		}
+		if a.allDecimal {
+			return a.decimalSum.Quo(a.count, decimalAvgPlaces)
+		}
+		return a.floatSum / float64(a.count)
+	default:
+		return a.best
+	}
+}
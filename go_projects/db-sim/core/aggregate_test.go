@@ -0,0 +1,90 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func aggregate(t *testing.T, fn AggFunc, strict bool, values ...interface{}) (interface{}, error) {
+	t.Helper()
+	agg := NewAggregator(fn, strict)
+	for _, v := range values {
+		if err := agg.Add(v); err != nil {
+			return nil, err
+		}
+	}
+	return agg.Result(), nil
+}
+
+func TestAggregator_Promotion(t *testing.T) {
+	cases := []struct {
+		fn     AggFunc
+		values []interface{}
+		want   interface{}
+	}{
+		{AggSum, []interface{}{1, 2, 3}, 6},
+		{AggSum, []interface{}{1, 2.5}, 3.5},
+		{AggSum, []interface{}{math.MaxInt, 1}, float64(math.MaxInt) + 1},
+		{AggSum, nil, 0},
+		{AggAvg, []interface{}{1, 2}, 1.5},
+		{AggAvg, nil, nil},
+		{AggMin, []interface{}{3, 2.5, 7}, 2.5},
+		{AggMax, []interface{}{3, 2.5, 7}, 7},
+		{AggMax, []interface{}{"x", 1}, 1},
+		{AggCount, []interface{}{1, "x", ListValue{}}, 3},
+		{AggSum, []interface{}{1, "x", true, NullValue{}}, 1},
+	}
+	for _, c := range cases {
+		got, err := aggregate(t, c.fn, false, c.values...)
+		if err != nil || got != c.want {
+			t.Errorf("%s %v: got %#v, %v; want %#v", c.fn, c.values, got, err, c.want)
+		}
+	}
+}
+
+func TestAggregator_StrictRejectsNonNumeric(t *testing.T) {
+	if _, err := aggregate(t, AggSum, true, 1, "x"); !errors.Is(err, ErrWrongKind) {
+		t.Fatalf("expected ErrWrongKind, got %v", err)
+	}
+	if got, err := aggregate(t, AggCount, true, 1, "x"); err != nil || got != 2 {
+		t.Fatalf("COUNT should accept anything: %v, %v", got, err)
+	}
+	if _, err := ParseAggFunc("median"); !errors.Is(err, ErrParse) {
+		t.Fatalf("expected ErrParse, got %v", err)
+	}
+}
+
+func TestAggregator_DecimalsStayExact(t *testing.T) {
+	dec := func(s string) Decimal {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	cases := []struct {
+		fn     AggFunc
+		values []interface{}
+		want   string
+	}{
+		{AggSum, []interface{}{dec("0.1"), dec("0.1"), dec("0.1")}, "0.3"},
+		{AggSum, []interface{}{dec("19.99"), dec("0.01")}, "20.00"},
+		{AggSum, []interface{}{dec("1"), 2}, "3"},
+		{AggSum, []interface{}{dec("0.1"), 0.2}, "0.30000000000000004"},
+		{AggAvg, []interface{}{dec("1.00"), dec("2.00")}, "1.50"},
+		{AggAvg, []interface{}{dec("1.00"), dec("1.00"), dec("2.00")}, "1.33333333"},
+		{AggAvg, []interface{}{dec("-0.01"), dec("0.00"), dec("0.00")}, "-0.00333333"},
+		{AggMin, []interface{}{dec("0.30000000000000000001"), dec("0.3")}, "0.3"},
+		{AggMax, []interface{}{dec("0.3"), dec("0.30000000000000000001")}, "0.30000000000000000001"},
+	}
+	for _, c := range cases {
+		got, err := aggregate(t, c.fn, false, c.values...)
+		if err != nil || FormatValue(got) != c.want {
+			t.Errorf("%s %v: got %v, %v; want %s", c.fn, c.values, FormatValue(got), err, c.want)
+		}
+	}
+	if got, _ := aggregate(t, AggSum, false, dec("0.1"), dec("0.2")); KindOf(got) != KindOf(dec("0")) {
+		t.Errorf("SUM of DECIMALs is a %s", KindOf(got))
+	}
+}
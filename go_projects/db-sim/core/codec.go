@@ -0,0 +1,280 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// The binary value encoding shared by snapshots and the disk engine: a one
+// byte tag followed by the value. Integers are varints, floats their IEEE
+// bits, strings and byte slices a uvarint length and the bytes; TIMESTAMP and
+// DECIMAL are kept as their canonical text so no precision or offset is lost.
+// Containers carry a count, then their members.
+const (
+	tagInt    byte = 'i'
+	tagFloat  byte = 'f'
+	tagString byte = 's'
+	tagObject byte = 'o'
+	tagList   byte = 'l'
+
+	tagBool      byte = 'b'
+	tagNull      byte = 'n'
+	tagTimestamp byte = 't'
+	tagDecimal   byte = 'd'
+	tagBytes     byte = 'y'
+
+	tagSet       byte = 'S'
+	tagSortedSet byte = 'Z'
+)
+
+var errTrailingData = errors.New("trailing data")
+
+// maxValueDepth bounds how deeply containers may nest in decoded data.
+const maxValueDepth = 1000
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// AppendValue appends the encoding of v to buf.
+func AppendValue(buf []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case int:
+		buf = append(buf, tagInt)
+		return binary.AppendVarint(buf, int64(val)), nil
+	case float64:
+		buf = append(buf, tagFloat)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(val)), nil
+	case string:
+		buf = append(buf, tagString)
+		return appendString(buf, val), nil
+	case bool:
+		buf = append(buf, tagBool)
+		if val {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case NullValue:
+		return append(buf, tagNull), nil
+	case time.Time:
+		buf = append(buf, tagTimestamp)
+		return appendString(buf, val.Format(time.RFC3339Nano)), nil
+	case Decimal:
+		buf = append(buf, tagDecimal)
+		return appendString(buf, val.ToString()), nil
+	case []byte:
+		buf = append(buf, tagBytes)
+		return appendString(buf, string(val)), nil
+	case ObjectValue:
+		names := make([]string, 0, len(val.Data))
+		for name := range val.Data {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		buf = append(buf, tagObject)
+		buf = binary.AppendUvarint(buf, uint64(len(names)))
+		for _, name := range names {
+			buf = appendString(buf, name)
+			var err error
+			if buf, err = AppendValue(buf, val.Data[name]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case ListValue:
+		buf = append(buf, tagList)
+		buf = binary.AppendUvarint(buf, uint64(len(val.Data)))
+		for _, item := range val.Data {
+			var err error
+			if buf, err = AppendValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case SetValue:
+		buf = append(buf, tagSet)
+		buf = binary.AppendUvarint(buf, uint64(val.Len()))
+		for _, m := range val.Members() {
+			buf = appendString(buf, m)
+		}
+		return buf, nil
+	case SortedSetValue:
+		buf = append(buf, tagSortedSet)
+		buf = binary.AppendUvarint(buf, uint64(val.Len()))
+		for _, e := range val.Entries() {
+			buf = appendString(buf, e.Member)
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(e.Score))
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("cannot snapshot value of type %T", v)
+	}
+}
+
+// DecodeValue reads one value written by AppendValue from the front of buf
+// and returns the bytes after it.
+func DecodeValue(buf []byte) (interface{}, []byte, error) {
+	d := &valueDecoder{buf: buf}
+	v := d.value(0)
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	return v, d.buf, nil
+}
+
+// DecodeValueExact is DecodeValue for a buf holding exactly one value.
+func DecodeValueExact(buf []byte) (interface{}, error) {
+	v, rest, err := DecodeValue(buf)
+	if err == nil && len(rest) != 0 {
+		err = errTrailingData
+	}
+	return v, err
+}
+
+type valueDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *valueDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+	d.buf = nil
+}
+
+func (d *valueDecoder) take(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *valueDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("bad length")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *valueDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("bad integer")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *valueDecoder) string() string {
+	return string(d.take(d.uvarint()))
+}
+
+func (d *valueDecoder) value(depth int) interface{} {
+	if depth > maxValueDepth {
+		d.fail("nesting too deep")
+		return nil
+	}
+	tag := d.take(1)
+	if tag == nil {
+		return nil
+	}
+
+	switch tag[0] {
+	case tagInt:
+		return int(d.varint())
+	case tagFloat:
+		b := d.take(8)
+		if b == nil {
+			return nil
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case tagString:
+		return d.string()
+	case tagBool:
+		b := d.take(1)
+		if b == nil {
+			return nil
+		}
+		return b[0] != 0
+	case tagNull:
+		return NullValue{}
+	case tagTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, d.string())
+		if err != nil && d.err == nil {
+			d.fail("bad timestamp")
+		}
+		return t
+	case tagDecimal:
+		dec, err := ParseDecimal(d.string())
+		if err != nil && d.err == nil {
+			d.fail("bad decimal")
+		}
+		return dec
+	case tagBytes:
+		return append([]byte(nil), d.take(d.uvarint())...)
+	case tagObject:
+		obj := NewObjectValue()
+		n := d.uvarint()
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			name := d.string()
+			obj.Data[name] = d.value(depth + 1)
+		}
+		return obj
+	case tagList:
+		n := d.uvarint()
+		if n > uint64(len(d.buf)) {
+			d.fail("bad list length")
+			return nil
+		}
+		list := ListValue{Data: make([]interface{}, 0, n)}
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			list.Data = append(list.Data, d.value(depth+1))
+		}
+		return list
+	case tagSet:
+		var set SetValue
+		n := d.uvarint()
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			set, _ = set.Add(d.string())
+		}
+		return set
+	case tagSortedSet:
+		var zset SortedSetValue
+		n := d.uvarint()
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			member := d.string()
+			b := d.take(8)
+			if b == nil {
+				return nil
+			}
+			zset, _ = zset.Add(member, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+		return zset
+	default:
+		d.fail("unknown value tag %q", tag[0])
+		return nil
+	}
+}
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadType    = errors.New("bad type")
+	ErrParse      = errors.New("parse error")
+	ErrWrongArity = errors.New("wrong number of arguments")
+	ErrWrongKind  = errors.New("wrong kind of value")
+	ErrNoSuchKey  = errors.New("no such key")
+)
+
+var ErrIncomplete = fmt.Errorf("%w: incomplete value definition", ErrWrongArity)
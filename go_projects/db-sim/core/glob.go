@@ -0,0 +1,84 @@
+package core
+
+// MatchGlob reports whether s matches a Redis-style glob pattern: '*' matches
+// any run of characters, '?' one character, "[abc]", "[a-z]" and "[^abc]"
+// a character class, and '\' escapes the next character.
+func MatchGlob(pattern, s string) bool {
+	p, i := 0, 0
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starI = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(pattern, p, s[i]); ok && matched {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the class starting at pattern[p] == '['. It
+// returns the index just past the class; ok is false for an unterminated
+// class, which then matches nothing.
+func matchClass(pattern string, p int, c byte) (matched bool, next int, ok bool) {
+	p++
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+	for first := true; p < len(pattern); first = false {
+		if pattern[p] == ']' && !first {
+			return matched != negate, p + 1, true
+		}
+		lo := pattern[p]
+		if lo == '\\' && p+1 < len(pattern) {
+			p++
+			lo = pattern[p]
+		}
+		hi := lo
+		if p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']' {
+			hi = pattern[p+2]
+			p += 2
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+		p++
+	}
+	return false, p, false
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"encoding/base64"
 	"strconv"
+	"time"
 )
 
 type Printable interface {
@@ -20,6 +22,12 @@ func FormatValue(v interface{}) string {
 		return strconv.FormatFloat(val, 'f', -1, 64)
 	case string:
 		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val)
 	default:
 		return ""
 	}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONTree converts a stored value into the plain maps, slices and
+// json.Numbers encoding/json writes. FLOATs always carry a decimal point so
+// they come back as FLOAT; TIMESTAMP and BYTES become strings and DECIMAL a
+// number, so those three read back as STRING, STRING and FLOAT or INT. A SET
+// becomes an array of its members and a ZSET an object of member scores.
+func JSONTree(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case int:
+		return json.Number(strconv.Itoa(val)), nil
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return nil, fmt.Errorf("%w: %v has no JSON form", ErrBadType, val)
+		}
+		s := strconv.FormatFloat(val, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return json.Number(s), nil
+	case string:
+		return val, nil
+	case bool:
+		return val, nil
+	case NullValue:
+		return nil, nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case Decimal:
+		return json.Number(val.ToString()), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val), nil
+	case ObjectValue:
+		obj := make(map[string]interface{}, len(val.Data))
+		for name, item := range val.Data {
+			j, err := JSONTree(item)
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = j
+		}
+		return obj, nil
+	case ListValue:
+		list := make([]interface{}, len(val.Data))
+		for i, item := range val.Data {
+			j, err := JSONTree(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = j
+		}
+		return list, nil
+	case SetValue:
+		members := val.Members()
+		list := make([]interface{}, len(members))
+		for i, m := range members {
+			list[i] = m
+		}
+		return list, nil
+	case SortedSetValue:
+		obj := make(map[string]interface{}, val.Len())
+		for _, e := range val.Entries() {
+			j, err := JSONTree(e.Score)
+			if err != nil {
+				return nil, err
+			}
+			obj[e.Member] = j
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported value of type %T", ErrBadType, v)
+	}
+}
+
+// ToJSON encodes v as compact JSON.
+func ToJSON(v interface{}) ([]byte, error) {
+	tree, err := JSONTree(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(tree); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// FromJSON decodes one JSON document into a stored value: objects become
+// ObjectValue, arrays ListValue, null NULL, and numbers INT when written
+// without a fraction or exponent and in range, FLOAT otherwise.
+func FromJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data after JSON value", ErrParse)
+	}
+	return FromJSONTree(tree)
+}
+
+// FromJSONTree converts what encoding/json decoded with UseNumber.
+func FromJSONTree(tree interface{}) (interface{}, error) {
+	switch val := tree.(type) {
+	case nil:
+		return NullValue{}, nil
+	case bool, string:
+		return val, nil
+	case json.Number:
+		s := string(val)
+		if !strings.ContainsAny(s, ".eE") {
+			if n, err := strconv.Atoi(s); err == nil {
+				return n, nil
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: number %s out of range", ErrParse, s)
+		}
+		return f, nil
+	case map[string]interface{}:
+		obj := NewObjectValue()
+		for name, item := range val {
+			v, err := FromJSONTree(item)
+			if err != nil {
+				return nil, err
+			}
+			obj.Data[name] = v
+		}
+		return obj, nil
+	case []interface{}:
+		list := ListValue{Data: make([]interface{}, len(val))}
+		for i, item := range val {
+			v, err := FromJSONTree(item)
+			if err != nil {
+				return nil, err
+			}
+			list.Data[i] = v
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected JSON value %T", ErrParse, tree)
+	}
+}
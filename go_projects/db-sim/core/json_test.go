@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSON_RoundTripKeepsIntAndFloat(t *testing.T) {
+	tokens := strings.Fields("OBJECT 5 age INT 30 score FLOAT 5 name STRING <b&b> tags LIST 3 INT 1 FLOAT 2.5 BOOL true gone NULL null")
+	v, _, err := ParseValue(tokens[0], tokens[1:])
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	data, err := ToJSON(v)
+	if err != nil {
+		t.Fatalf("to json: %v", err)
+	}
+	want := `{"age":30,"gone":null,"name":"<b&b>","score":5.0,"tags":[1,2.5,true]}`
+	if string(data) != want {
+		t.Fatalf("got %s", data)
+	}
+
+	back, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("from json: %v", err)
+	}
+	obj := back.(ObjectValue)
+	if obj.Data["age"] != 30 || obj.Data["score"] != 5.0 || obj.Data["gone"] != (NullValue{}) {
+		t.Fatalf("types not kept: %#v", obj.Data)
+	}
+	if got := FormatValue(back); got != FormatValue(v) {
+		t.Fatalf("round trip gave %s", got)
+	}
+}
+
+func TestFromJSON_Numbers(t *testing.T) {
+	cases := map[string]interface{}{
+		"7":                    7,
+		"-7":                   -7,
+		"7.0":                  7.0,
+		"1e3":                  1000.0,
+		"99999999999999999999": 1e20,
+		`"7"`:                  "7",
+	}
+	for in, want := range cases {
+		if got, err := FromJSON([]byte(in)); err != nil || got != want {
+			t.Errorf("%s: got %#v, %v", in, got, err)
+		}
+	}
+
+	for _, in := range []string{"", "{", "1 2", "{} }"} {
+		if _, err := FromJSON([]byte(in)); !errors.Is(err, ErrParse) {
+			t.Errorf("%q: expected ErrParse, got %v", in, err)
+		}
+	}
+}
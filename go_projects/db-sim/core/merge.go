@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var ErrMergeConflict = errors.New("merge conflict")
+
+type MergeStrategy int
+
+const (
+	MergeSourceWins MergeStrategy = iota
+	MergeTargetWins
+	MergeFailOnConflict
+	MergeConcatLists
+)
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch strings.ToLower(s) {
+	case "source":
+		return MergeSourceWins, nil
+	case "target":
+		return MergeTargetWins, nil
+	case "error":
+		return MergeFailOnConflict, nil
+	case "concat-lists":
+		return MergeConcatLists, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown merge strategy '%s'", ErrParse, s)
+	}
+}
+
+type MergeOptions struct {
+	Deep     bool
+	Strategy MergeStrategy
+}
+
+// Merge combines source into a copy of target and returns it along with the
+// paths of the fields that conflicted, i.e. exist on both sides with
+// different values. With Deep set, fields that are objects on both sides are
+// merged recursively instead of conflicting. Everything taken from source is
+// deep-copied, so the result shares nothing with it.
+func Merge(target, source ObjectValue, opts MergeOptions) (ObjectValue, []string, error) {
+	var conflicts []string
+	merged := mergeInto(target, source, opts, "", &conflicts)
+	sort.Strings(conflicts)
+	if opts.Strategy == MergeFailOnConflict && len(conflicts) > 0 {
+		return ObjectValue{}, conflicts, fmt.Errorf("%w on %s", ErrMergeConflict, strings.Join(conflicts, ", "))
+	}
+	return merged, conflicts, nil
+}
+
+func mergeInto(target, source ObjectValue, opts MergeOptions, prefix string, conflicts *[]string) ObjectValue {
+	merged := NewObjectValue()
+	for k, v := range target.Data {
+		merged.Data[k] = v
+	}
+
+	for k, sv := range source.Data {
+		tv, exists := target.Data[k]
+		if !exists {
+			merged.Data[k] = Clone(sv)
+			continue
+		}
+
+		tObj, tIsObj := tv.(ObjectValue)
+		sObj, sIsObj := sv.(ObjectValue)
+		if opts.Deep && tIsObj && sIsObj {
+			merged.Data[k] = mergeInto(tObj, sObj, opts, prefix+k+".", conflicts)
+			continue
+		}
+		if reflect.DeepEqual(tv, sv) {
+			continue
+		}
+
+		*conflicts = append(*conflicts, prefix+k)
+		switch opts.Strategy {
+		case MergeTargetWins, MergeFailOnConflict:
+		case MergeConcatLists:
+			tList, tIsList := tv.(ListValue)
+			sList, sIsList := sv.(ListValue)
+			if tIsList && sIsList {
+				data := make([]interface{}, 0, len(tList.Data)+len(sList.Data))
+				data = append(data, tList.Data...)
+				data = append(data, Clone(sList).(ListValue).Data...)
+				merged.Data[k] = ListValue{Data: data}
+			} else {
+				merged.Data[k] = Clone(sv)
+			}
+		default:
+			merged.Data[k] = Clone(sv)
+		}
+	}
+	return merged
+}
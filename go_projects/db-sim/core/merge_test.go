@@ -0,0 +1,64 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func object(t *testing.T, def string, n int) ObjectValue {
+	t.Helper()
+	obj, rest, err := ParseFields(n, strings.Fields(def))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("bad test object %q: %v", def, err)
+	}
+	return obj
+}
+
+func TestMerge_Strategies(t *testing.T) {
+	target := object(t, "name STRING bob addr OBJECT 2 city STRING Paris zip INT 1 tags LIST 1 STRING a", 3)
+	source := object(t, "name STRING rob addr OBJECT 1 city STRING Lyon tags LIST 1 STRING b age INT 3", 4)
+
+	cases := []struct {
+		opts      MergeOptions
+		want      string
+		conflicts string
+	}{
+		{MergeOptions{}, "{addr:{city:Lyon},age:3,name:rob,tags:[b]}", "[addr name tags]"},
+		{MergeOptions{Deep: true}, "{addr:{city:Lyon,zip:1},age:3,name:rob,tags:[b]}", "[addr.city name tags]"},
+		{MergeOptions{Deep: true, Strategy: MergeTargetWins}, "{addr:{city:Paris,zip:1},age:3,name:bob,tags:[a]}", "[addr.city name tags]"},
+		{MergeOptions{Deep: true, Strategy: MergeConcatLists}, "{addr:{city:Lyon,zip:1},age:3,name:rob,tags:[a,b]}", "[addr.city name tags]"},
+	}
+	for _, c := range cases {
+		merged, conflicts, err := Merge(target, source, c.opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", c.opts, err)
+		}
+		if got := merged.ToString(); got != c.want {
+			t.Errorf("%+v: got %s, want %s", c.opts, got, c.want)
+		}
+		if got := fmt.Sprint(conflicts); got != c.conflicts {
+			t.Errorf("%+v: conflicts %s, want %s", c.opts, got, c.conflicts)
+		}
+	}
+
+	if _, _, err := Merge(target, source, MergeOptions{Strategy: MergeFailOnConflict}); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected ErrMergeConflict, got %v", err)
+	}
+	if got := target.ToString(); got != "{addr:{city:Paris,zip:1},name:bob,tags:[a]}" {
+		t.Fatalf("target modified: %s", got)
+	}
+}
+
+func TestMerge_DoesNotShareSourceValues(t *testing.T) {
+	target := NewObjectValue()
+	source := object(t, "addr OBJECT 1 city STRING Paris", 1)
+
+	merged, _, _ := Merge(target, source, MergeOptions{Deep: true})
+	source.Data["addr"].(ObjectValue).Data["city"] = "Lyon"
+
+	if got := merged.ToString(); got != "{addr:{city:Paris}}" {
+		t.Fatalf("edit to source leaked into result: %s", got)
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var ErrNoSuchPath = errors.New("no such path")
+
+// PathSegment is one step of a path: an object field (".name") or a list
+// index ("[2]"). Negative indexes count from the end of the list.
+type PathSegment struct {
+	Field   string
+	Index   int
+	IsIndex bool
+}
+
+func (s PathSegment) String() string {
+	if s.IsIndex {
+		return "[" + strconv.Itoa(s.Index) + "]"
+	}
+	return "." + s.Field
+}
+
+func FormatPath(key string, path []PathSegment) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, seg := range path {
+		sb.WriteString(seg.String())
+	}
+	return sb.String()
+}
+
+// PathRoot returns the key a path such as "user.phones[0]" starts from.
+func PathRoot(s string) string {
+	if i := strings.IndexAny(s, ".["); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// CheckKey rejects a new key name that commands taking a path would read as
+// a path, since such a key could be created but never addressed, logged or
+// replayed again.
+func CheckKey(key string) error {
+	if PathRoot(key) != key {
+		return fmt.Errorf("%w: key '%s' may not contain '.' or '['", ErrParse, key)
+	}
+	return nil
+}
+
+func ParsePath(s string) (string, []PathSegment, error) {
+	key := PathRoot(s)
+	if key == "" {
+		return "", nil, fmt.Errorf("%w: empty key in path '%s'", ErrParse, s)
+	}
+
+	var path []PathSegment
+	rest := s[len(key):]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			name := PathRoot(rest)
+			if name == "" {
+				return "", nil, fmt.Errorf("%w: empty field name in path '%s'", ErrParse, s)
+			}
+			path = append(path, PathSegment{Field: name})
+			rest = rest[len(name):]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", nil, fmt.Errorf("%w: unterminated index in path '%s'", ErrParse, s)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: invalid index '%s' in path '%s'", ErrParse, rest[1:end], s)
+			}
+			path = append(path, PathSegment{Index: idx, IsIndex: true})
+			rest = rest[end+1:]
+		default:
+			return "", nil, fmt.Errorf("%w: unexpected '%c' in path '%s'", ErrParse, rest[0], s)
+		}
+	}
+	return key, path, nil
+}
+
+func listIndex(idx, n int) (int, bool) {
+	if idx < 0 {
+		idx += n
+	}
+	return idx, idx >= 0 && idx < n
+}
+
+func GetPath(key string, v interface{}, path []PathSegment) (interface{}, error) {
+	for i, seg := range path {
+		switch val := v.(type) {
+		case ObjectValue:
+			if seg.IsIndex {
+				return nil, fmt.Errorf("%w: '%s' is an object, not a list", ErrWrongKind, FormatPath(key, path[:i]))
+			}
+			child, ok := val.Data[seg.Field]
+			if !ok {
+				return nil, fmt.Errorf("%w '%s'", ErrNoSuchPath, FormatPath(key, path[:i+1]))
+			}
+			v = child
+		case ListValue:
+			if !seg.IsIndex {
+				return nil, fmt.Errorf("%w: '%s' is a list, not an object", ErrWrongKind, FormatPath(key, path[:i]))
+			}
+			idx, ok := listIndex(seg.Index, len(val.Data))
+			if !ok {
+				return nil, fmt.Errorf("%w '%s': index out of range", ErrNoSuchPath, FormatPath(key, path[:i+1]))
+			}
+			v = val.Data[idx]
+		default:
+			return nil, fmt.Errorf("%w: '%s' is a scalar", ErrWrongKind, FormatPath(key, path[:i]))
+		}
+	}
+	return v, nil
+}
+
+// UpdatePath replaces the value at path inside v with fn's result and returns
+// the new root. Objects and lists along the path are copied, never edited in
+// place. Only the last field of a path may be missing, in which case fn is
+// called with exists set to false and the field is added.
+func UpdatePath(key string, v interface{}, path []PathSegment, fn func(old interface{}, exists bool) (interface{}, error)) (interface{}, error) {
+	return updatePath(key, v, path, 0, fn)
+}
+
+func updatePath(key string, v interface{}, path []PathSegment, i int, fn func(interface{}, bool) (interface{}, error)) (interface{}, error) {
+	if i == len(path) {
+		return fn(v, true)
+	}
+	seg := path[i]
+
+	switch val := v.(type) {
+	case ObjectValue:
+		if seg.IsIndex {
+			return nil, fmt.Errorf("%w: '%s' is an object, not a list", ErrWrongKind, FormatPath(key, path[:i]))
+		}
+		var newChild interface{}
+		child, ok := val.Data[seg.Field]
+		switch {
+		case ok:
+			var err error
+			if newChild, err = updatePath(key, child, path, i+1, fn); err != nil {
+				return nil, err
+			}
+		case i == len(path)-1:
+			var err error
+			if newChild, err = fn(nil, false); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w '%s'", ErrNoSuchPath, FormatPath(key, path[:i+1]))
+		}
+
+		obj := NewObjectValue()
+		for k, item := range val.Data {
+			obj.Data[k] = item
+		}
+		obj.Data[seg.Field] = newChild
+		return obj, nil
+
+	case ListValue:
+		if !seg.IsIndex {
+			return nil, fmt.Errorf("%w: '%s' is a list, not an object", ErrWrongKind, FormatPath(key, path[:i]))
+		}
+		idx, ok := listIndex(seg.Index, len(val.Data))
+		if !ok {
+			return nil, fmt.Errorf("%w '%s': index out of range", ErrNoSuchPath, FormatPath(key, path[:i+1]))
+		}
+		newChild, err := updatePath(key, val.Data[idx], path, i+1, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		list := ListValue{Data: make([]interface{}, len(val.Data))}
+		copy(list.Data, val.Data)
+		list.Data[idx] = newChild
+		return list, nil
+
+	default:
+		return nil, fmt.Errorf("%w: '%s' is a scalar", ErrWrongKind, FormatPath(key, path[:i]))
+	}
+}
+
+// PushOnto appends newVal to existing the way PUSH does: a missing value
+// becomes a one-element list and a non-list value becomes the first element.
+// existing is left as it was. A list PushOnto built is appended to in place
+// while it has room and no longer list shares its array, so building a list
+// one push at a time copies it only when it grows.
+func PushOnto(existing interface{}, exists bool, newVal interface{}) ListValue {
+	if !exists {
+		return newPushedList(nil, newVal)
+	}
+	listVal, ok := existing.(ListValue)
+	if !ok {
+		return newPushedList([]interface{}{existing}, newVal)
+	}
+	n := len(listVal.Data)
+	if listVal.tail != nil && n < cap(listVal.Data) && listVal.tail.CompareAndSwap(int64(n), int64(n+1)) {
+		return ListValue{
+			Data: append(listVal.Data, newVal),
+			tail: listVal.tail,
+			size: listVal.size + SizeOf(newVal),
+		}
+	}
+	return newPushedList(listVal.Data, newVal)
+}
+
+// newPushedList copies items and newVal into a new array with room to grow.
+func newPushedList(items []interface{}, newVal interface{}) ListValue {
+	data := make([]interface{}, len(items)+1, 2*len(items)+4)
+	copy(data, items)
+	data[len(items)] = newVal
+	list := ListValue{Data: data, tail: new(atomic.Int64)}
+	list.tail.Store(int64(len(data)))
+	for _, item := range data {
+		list.size += SizeOf(item)
+	}
+	return list
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	key, path, err := ParsePath("user.phones[-1].kind")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if key != "user" || len(path) != 3 || path[1].Index != -1 || !path[1].IsIndex || path[2].Field != "kind" {
+		t.Fatalf("unexpected parse: %s %+v", key, path)
+	}
+	if got := FormatPath(key, path); got != "user.phones[-1].kind" {
+		t.Fatalf("FormatPath: got %s", got)
+	}
+
+	for _, bad := range []string{".a", "a..b", "a[x]", "a[1", "a[1]b"} {
+		if _, _, err := ParsePath(bad); !errors.Is(err, ErrParse) {
+			t.Errorf("%q: expected ErrParse, got %v", bad, err)
+		}
+	}
+}
+
+func TestUpdatePath_CopiesAlongThePath(t *testing.T) {
+	inner := NewObjectValue()
+	inner.Data["city"] = "Paris"
+	root := NewObjectValue()
+	root.Data["address"] = inner
+	root.Data["tags"] = ListValue{Data: []interface{}{"a", "b"}}
+
+	_, path, _ := ParsePath("u.address.city")
+	updated, err := UpdatePath("u", root, path, func(interface{}, bool) (interface{}, error) {
+		return "Lyon", nil
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := FormatValue(updated); got != "{address:{city:Lyon},tags:[a,b]}" {
+		t.Fatalf("updated: %s", got)
+	}
+	if got := FormatValue(root); got != "{address:{city:Paris},tags:[a,b]}" {
+		t.Fatalf("original was modified: %s", got)
+	}
+
+	cases := map[string]error{
+		"u.missing.x": ErrNoSuchPath,
+		"u.tags[5]":   ErrNoSuchPath,
+		"u.tags.x":    ErrWrongKind,
+		"u[0]":        ErrWrongKind,
+	}
+	for p, want := range cases {
+		_, path, _ := ParsePath(p)
+		_, err := UpdatePath("u", root, path, func(interface{}, bool) (interface{}, error) { return 1, nil })
+		if !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", p, want, err)
+		}
+	}
+}
+
+func TestPushOnto_SharesOnlyFromTheLongestList(t *testing.T) {
+	var list interface{} = PushOnto(nil, false, 0)
+	var kept []ListValue
+	for i := 1; i < 100; i++ {
+		kept = append(kept, list.(ListValue))
+		list = PushOnto(list, true, i)
+	}
+	if got := list.(ListValue); len(got.Data) != 100 || got.Data[99] != 99 {
+		t.Fatalf("built list %v", FormatValue(got))
+	}
+
+	branch := PushOnto(kept[10], true, "x")
+	again := PushOnto(kept[10], true, "y")
+	if branch.Data[11] != "x" || again.Data[11] != "y" || kept[11].Data[10] != 10 {
+		t.Fatalf("pushes onto an older list clobbered another: %v %v", branch.Data[11], again.Data[11])
+	}
+	if got := list.(ListValue); got.Data[11] != 11 {
+		t.Fatalf("the longest list lost item 11: %v", got.Data[11])
+	}
+
+	walked := ListValue{Data: append([]interface{}(nil), list.(ListValue).Data...)}
+	if SizeOf(list) != SizeOf(walked) {
+		t.Fatalf("SizeOf of the pushed list is %d, walking it gives %d", SizeOf(list), SizeOf(walked))
+	}
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseFieldPath parses a field reference such as "age" or "address.city",
+// relative to an object.
+func ParseFieldPath(field string) ([]PathSegment, error) {
+	root, rest, err := ParsePath(field)
+	if err != nil {
+		return nil, err
+	}
+	return append([]PathSegment{{Field: root}}, rest...), nil
+}
+
+// FieldValue reads the value at path inside v, which must be an object.
+func FieldValue(v interface{}, path []PathSegment) (interface{}, bool) {
+	if _, ok := v.(ObjectValue); !ok {
+		return nil, false
+	}
+	val, err := GetPath("", v, path)
+	return val, err == nil
+}
+
+// Numeric returns the value of an INT, FLOAT or DECIMAL as a float64.
+func Numeric(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), true
+	case float64:
+		return val, !math.IsNaN(val)
+	case Decimal:
+		f, _ := val.Rat().Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+type CompareOp string
+
+const (
+	OpEq CompareOp = "="
+	OpNe CompareOp = "!="
+	OpGt CompareOp = ">"
+	OpGe CompareOp = ">="
+	OpLt CompareOp = "<"
+	OpLe CompareOp = "<="
+	OpIn CompareOp = "IN"
+)
+
+// Condition is a test on one object field: "field op value" or
+// "field IN (v1, v2, ...)". Equality compares numerically when both sides
+// are numbers and by formatted text otherwise; the ordering operators need
+// a numeric field and value.
+type Condition struct {
+	Field  string
+	Path   []PathSegment
+	Op     CompareOp
+	Values []string
+	nums   []float64
+	isNum  []bool
+}
+
+// ParseCondition reads a condition from the front of args and returns the
+// arguments after it.
+func ParseCondition(args []string) (Condition, []string, error) {
+	if len(args) < 3 {
+		return Condition{}, nil, fmt.Errorf("%w: condition needs 'field op value'", ErrWrongArity)
+	}
+	path, err := ParseFieldPath(args[0])
+	if err != nil {
+		return Condition{}, nil, err
+	}
+	c := Condition{Field: args[0], Path: path, Op: CompareOp(strings.ToUpper(args[1]))}
+
+	rest := args[2:]
+	switch c.Op {
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe:
+		c.Values, rest = rest[:1], rest[1:]
+	case OpIn:
+		if c.Values, rest, err = parseInList(rest); err != nil {
+			return Condition{}, nil, err
+		}
+	default:
+		return Condition{}, nil, fmt.Errorf("%w: unknown operator '%s'", ErrParse, args[1])
+	}
+
+	for _, v := range c.Values {
+		n, err := strconv.ParseFloat(v, 64)
+		ok := err == nil && !math.IsInf(n, 0) && !math.IsNaN(n)
+		c.nums = append(c.nums, n)
+		c.isNum = append(c.isNum, ok)
+	}
+	if c.Ordered() && !c.isNum[0] {
+		return Condition{}, nil, fmt.Errorf("%w: '%s' needs a number, got '%s'", ErrParse, c.Op, c.Values[0])
+	}
+	return c, rest, nil
+}
+
+// parseInList reads "(a, b, c)"; the parentheses and commas may be separate
+// arguments or attached to the values.
+func parseInList(args []string) ([]string, []string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "(") {
+		return nil, nil, fmt.Errorf("%w: IN needs a list in parentheses", ErrParse)
+	}
+	var values []string
+	for i, arg := range args {
+		if i == 0 {
+			arg = arg[1:]
+		}
+		last := strings.HasSuffix(arg, ")")
+		if last {
+			arg = arg[:len(arg)-1]
+		}
+		for _, part := range strings.Split(arg, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+		if last {
+			if len(values) == 0 {
+				return nil, nil, fmt.Errorf("%w: empty IN list", ErrParse)
+			}
+			return values, args[i+1:], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: unterminated IN list", ErrParse)
+}
+
+// Ordered reports whether the operator compares numbers by size.
+func (c Condition) Ordered() bool {
+	switch c.Op {
+	case OpGt, OpGe, OpLt, OpLe:
+		return true
+	default:
+		return false
+	}
+}
+
+// Num returns the i-th value as a number, if it is one.
+func (c Condition) Num(i int) (float64, bool) {
+	return c.nums[i], c.isNum[i]
+}
+
+// Match reports whether the object v satisfies the condition.
+func (c Condition) Match(v interface{}) bool {
+	fv, ok := FieldValue(v, c.Path)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpEq, OpIn:
+		for i := range c.Values {
+			if c.equal(fv, i) {
+				return true
+			}
+		}
+		return false
+	case OpNe:
+		return !c.equal(fv, 0)
+	}
+
+	n, ok := Numeric(fv)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpGt:
+		return n > c.nums[0]
+	case OpGe:
+		return n >= c.nums[0]
+	case OpLt:
+		return n < c.nums[0]
+	default:
+		return n <= c.nums[0]
+	}
+}
+
+func (c Condition) equal(fv interface{}, i int) bool {
+	if n, ok := Numeric(fv); ok && c.isNum[i] {
+		return n == c.nums[i]
+	}
+	switch fv.(type) {
+	case ObjectValue, ListValue:
+		return false
+	}
+	return FormatValue(fv) == c.Values[i]
+}
+
+func (c Condition) String() string {
+	if c.Op == OpIn {
+		return c.Field + " IN (" + strings.Join(c.Values, ", ") + ")"
+	}
+	return c.Field + " " + string(c.Op) + " " + c.Values[0]
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCondition_Forms(t *testing.T) {
+	cases := []struct {
+		in, want, rest string
+	}{
+		{"age = 30", "age = 30", ""},
+		{"age >= 18 ORDER BY age", "age >= 18", "ORDER BY age"},
+		{"city IN (Paris, London) LIMIT 1", "city IN (Paris, London)", "LIMIT 1"},
+		{"city in (Paris,London,Rome)", "city IN (Paris, London, Rome)", ""},
+		{"address.zip IN ( 75001 )", "address.zip IN (75001)", ""},
+	}
+	for _, c := range cases {
+		cond, rest, err := ParseCondition(strings.Fields(c.in))
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if cond.String() != c.want || strings.Join(rest, " ") != c.rest {
+			t.Errorf("%s: got %q rest %q", c.in, cond.String(), rest)
+		}
+	}
+
+	for _, in := range []string{"age > old", "age ~ 3", "city IN Paris", "city IN (Paris", "city IN ()"} {
+		if _, _, err := ParseCondition(strings.Fields(in)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: expected ErrParse, got %v", in, err)
+		}
+	}
+}
+
+func TestCondition_Match(t *testing.T) {
+	obj := NewObjectValue()
+	obj.Data["age"] = 30
+	obj.Data["score"] = 7.5
+	obj.Data["zip"] = "075"
+	obj.Data["tags"] = ListValue{Data: []interface{}{"a"}}
+
+	cases := map[string]bool{
+		"age = 30":        true,
+		"age = 30.0":      true,
+		"age > 29.5":      true,
+		"age <= 29":       false,
+		"score < 8":       true,
+		"zip = 075":       true,
+		"zip = 75":        false,
+		"zip > 1":         false,
+		"age IN (1, 30)":  true,
+		"age != 31":       true,
+		"tags = [a]":      false,
+		"missing = 1":     false,
+		"missing != 1":    false,
+		"age IN (x, y)":   false,
+		"score IN (7.50)": true,
+	}
+	for in, want := range cases {
+		cond, _, err := ParseCondition(strings.Fields(in))
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got := cond.Match(obj); got != want {
+			t.Errorf("%s: got %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// NullValue is the explicit null stored by the NULL type, as opposed to a
+// key that does not exist.
+type NullValue struct{}
+
+func (NullValue) ToString() string {
+	return "null"
+}
+
+// Decimal is an exact base-10 number, unscaled * 10^-scale, so amounts such
+// as 19.99 keep every digit they were written with.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func NewDecimal(unscaled *big.Int, scale int) Decimal {
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		digits = s[1:]
+	}
+	intPart, fracPart, hasDot := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" || !allDigits(intPart) || !allDigits(fracPart) || hasDot && fracPart == "" {
+		return Decimal{}, fmt.Errorf("%w: invalid DECIMAL '%s'", ErrParse, s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{unscaled: unscaled, scale: len(fracPart)}, nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) Unscaled() *big.Int {
+	return new(big.Int).Set(d.unscaled)
+}
+
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.rescaled(d.scale), pow10(d.scale))
+}
+
+// Add returns d + o exactly, at the larger of their scales.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	sum := d.rescaled(scale)
+	return Decimal{unscaled: sum.Add(sum, o.rescaled(scale)), scale: scale}
+}
+
+// Cmp compares d and o exactly and returns -1, 0 or +1.
+func (d Decimal) Cmp(o Decimal) int {
+	scale := max(d.scale, o.scale)
+	return d.rescaled(scale).Cmp(o.rescaled(scale))
+}
+
+// Quo returns d / n, rounded half to even at places digits past the scale
+// of d and with the zeros that leaves past that scale trimmed, so a quotient
+// that fits the scale of d keeps it.
+func (d Decimal) Quo(n, places int) Decimal {
+	scale := d.scale + places
+	den := big.NewInt(int64(n))
+	q, r := new(big.Int).QuoRem(d.rescaled(scale), den, new(big.Int))
+	half := new(big.Int).Abs(r)
+	switch half.Lsh(half, 1).CmpAbs(den) {
+	case 1:
+		q.Add(q, big.NewInt(int64(r.Sign()*den.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(r.Sign()*den.Sign())))
+		}
+	}
+	ten := big.NewInt(10)
+	for scale > d.scale && new(big.Int).Rem(q, ten).Sign() == 0 {
+		q.Quo(q, ten)
+		scale--
+	}
+	return Decimal{unscaled: q, scale: scale}
+}
+
+// rescaled returns a fresh unscaled value of d at scale, which must not be
+// below the scale of d.
+func (d Decimal) rescaled(scale int) *big.Int {
+	n := new(big.Int)
+	if d.unscaled != nil {
+		n.Set(d.unscaled)
+	}
+	return n.Mul(n, pow10(scale-d.scale))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) ToString() string {
+	if d.unscaled == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
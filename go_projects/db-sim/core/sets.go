@@ -0,0 +1,190 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SetValue is an unordered collection of distinct strings. Like every stored
+// value it is never changed in place: Add and Remove return a new set that
+// shares most of its structure with the old one. Members iterate in sorted
+// order.
+type SetValue struct {
+	root *treapNode
+	// bytes totals the member lengths for SizeOf.
+	bytes int
+}
+
+func NewSetValue(members ...string) SetValue {
+	var s SetValue
+	for _, m := range members {
+		s, _ = s.Add(m)
+	}
+	return s
+}
+
+func (s SetValue) Len() int {
+	return s.root.count()
+}
+
+func (s SetValue) Has(member string) bool {
+	_, ok := treapGet(s.root, treapKey{member: member})
+	return ok
+}
+
+// Add returns s with member in it and whether it was new.
+func (s SetValue) Add(member string) (SetValue, bool) {
+	if s.Has(member) {
+		return s, false
+	}
+	return SetValue{root: treapPut(s.root, treapKey{member: member}, 0), bytes: s.bytes + len(member)}, true
+}
+
+// Remove returns s without member and whether it was there.
+func (s SetValue) Remove(member string) (SetValue, bool) {
+	if !s.Has(member) {
+		return s, false
+	}
+	return SetValue{root: treapDelete(s.root, treapKey{member: member}), bytes: s.bytes - len(member)}, true
+}
+
+// Members lists the members in sorted order.
+func (s SetValue) Members() []string {
+	members := make([]string, 0, s.Len())
+	treapWalk(s.root, 0, func(n *treapNode) bool {
+		members = append(members, n.key.member)
+		return true
+	})
+	return members
+}
+
+func (s SetValue) ToString() string {
+	return "#{" + strings.Join(s.Members(), ",") + "}"
+}
+
+// ZEntry is one member of a sorted set with its score.
+type ZEntry struct {
+	Member string
+	Score  float64
+}
+
+// SortedSetValue holds distinct members ordered by score, ties broken by
+// member. Two persistent treaps back it: one in score order with subtree
+// sizes for ranks and ranges, one by member for score lookups. Like SetValue
+// it is read-only; Add and Remove return a new value.
+type SortedSetValue struct {
+	byScore  *treapNode
+	byMember *treapNode
+	bytes    int
+}
+
+func NewSortedSetValue(entries ...ZEntry) SortedSetValue {
+	var z SortedSetValue
+	for _, e := range entries {
+		z, _ = z.Add(e.Member, e.Score)
+	}
+	return z
+}
+
+func (z SortedSetValue) Len() int {
+	return z.byScore.count()
+}
+
+func (z SortedSetValue) Score(member string) (float64, bool) {
+	n, ok := treapGet(z.byMember, treapKey{member: member})
+	if !ok {
+		return 0, false
+	}
+	return n.val, true
+}
+
+// Add returns z with member at score and whether member was new.
+func (z SortedSetValue) Add(member string, score float64) (SortedSetValue, bool) {
+	old, exists := z.Score(member)
+	if exists && old == score {
+		return z, false
+	}
+	byScore, bytes := z.byScore, z.bytes
+	if exists {
+		byScore = treapDelete(byScore, treapKey{old, member})
+	} else {
+		bytes += len(member)
+	}
+	return SortedSetValue{
+		byScore:  treapPut(byScore, treapKey{score, member}, 0),
+		byMember: treapPut(z.byMember, treapKey{member: member}, score),
+		bytes:    bytes,
+	}, !exists
+}
+
+// Remove returns z without member and whether it was there.
+func (z SortedSetValue) Remove(member string) (SortedSetValue, bool) {
+	score, exists := z.Score(member)
+	if !exists {
+		return z, false
+	}
+	return SortedSetValue{
+		byScore:  treapDelete(z.byScore, treapKey{score, member}),
+		byMember: treapDelete(z.byMember, treapKey{member: member}),
+		bytes:    z.bytes - len(member),
+	}, true
+}
+
+// Rank is the 0-based position of member in score order.
+func (z SortedSetValue) Rank(member string) (int, bool) {
+	score, exists := z.Score(member)
+	if !exists {
+		return 0, false
+	}
+	return treapRank(z.byScore, treapKey{score, member}), true
+}
+
+// Range returns the entries with ranks start to stop inclusive, which the
+// caller has already clamped to the set.
+func (z SortedSetValue) Range(start, stop int) []ZEntry {
+	var entries []ZEntry
+	if start > stop {
+		return entries
+	}
+	treapWalk(z.byScore, start, func(n *treapNode) bool {
+		entries = append(entries, ZEntry{n.key.member, n.key.score})
+		return len(entries) <= stop-start
+	})
+	return entries
+}
+
+// ScoreBound is one end of a score range; Exclusive leaves Score itself out.
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// RangeByScore returns the entries with scores between min and max in order.
+func (z SortedSetValue) RangeByScore(min, max ScoreBound) []ZEntry {
+	var entries []ZEntry
+	from := treapRank(z.byScore, treapKey{score: min.Score})
+	treapWalk(z.byScore, from, func(n *treapNode) bool {
+		s := n.key.score
+		if s > max.Score || (max.Exclusive && s == max.Score) {
+			return false
+		}
+		if !(min.Exclusive && s == min.Score) {
+			entries = append(entries, ZEntry{n.key.member, s})
+		}
+		return true
+	})
+	return entries
+}
+
+// Entries lists every member in score order.
+func (z SortedSetValue) Entries() []ZEntry {
+	return z.Range(0, z.Len()-1)
+}
+
+func (z SortedSetValue) ToString() string {
+	var parts []string
+	for _, e := range z.Entries() {
+		parts = append(parts, e.Member+":"+strconv.FormatFloat(e.Score, 'f', -1, 64))
+	}
+	return "#[" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSetValue_AddRemoveKeepOldVersions(t *testing.T) {
+	a := NewSetValue("b", "a")
+	b, added := a.Add("c")
+	if !added {
+		t.Fatal("c should be new")
+	}
+	if _, added := b.Add("a"); added {
+		t.Fatal("a is already a member")
+	}
+	c, removed := b.Remove("a")
+	if !removed {
+		t.Fatal("a should be removed")
+	}
+
+	if got := a.ToString(); got != "#{a,b}" {
+		t.Errorf("a: got %s", got)
+	}
+	if got := b.ToString(); got != "#{a,b,c}" {
+		t.Errorf("b: got %s", got)
+	}
+	if got := c.ToString(); got != "#{b,c}" {
+		t.Errorf("c: got %s", got)
+	}
+}
+
+func TestSortedSetValue_MatchesSortedReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var z SortedSetValue
+	ref := map[string]float64{}
+	for i := 0; i < 2000; i++ {
+		m := fmt.Sprintf("m%d", rng.Intn(300))
+		if rng.Intn(4) == 0 {
+			z, _ = z.Remove(m)
+			delete(ref, m)
+			continue
+		}
+		score := float64(rng.Intn(50))
+		z, _ = z.Add(m, score)
+		ref[m] = score
+	}
+
+	want := make([]ZEntry, 0, len(ref))
+	for m, s := range ref {
+		want = append(want, ZEntry{m, s})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].Score != want[j].Score {
+			return want[i].Score < want[j].Score
+		}
+		return want[i].Member < want[j].Member
+	})
+
+	if got := z.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries differ from reference")
+	}
+	for i, e := range want {
+		if rank, ok := z.Rank(e.Member); !ok || rank != i {
+			t.Fatalf("rank of %s: got %d, want %d", e.Member, rank, i)
+		}
+	}
+	if got := z.Range(10, 14); !reflect.DeepEqual(got, want[10:15]) {
+		t.Errorf("range 10..14: got %v", got)
+	}
+
+	var inRange []ZEntry
+	for _, e := range want {
+		if e.Score > 10 && e.Score <= 20 {
+			inRange = append(inRange, e)
+		}
+	}
+	got := z.RangeByScore(ScoreBound{10, true}, ScoreBound{20, false})
+	if !reflect.DeepEqual(got, inRange) {
+		t.Errorf("range by score (10 20]: got %d entries, want %d", len(got), len(inRange))
+	}
+}
+
+func TestSortedSetValue_AddMovesExistingMember(t *testing.T) {
+	z := NewSortedSetValue(ZEntry{"a", 1}, ZEntry{"b", 2})
+	z2, added := z.Add("a", 3)
+	if added {
+		t.Fatal("a is already a member")
+	}
+	if got := z2.ToString(); got != "#[b:2,a:3]" {
+		t.Errorf("got %s", got)
+	}
+	if got := z.ToString(); got != "#[a:1,b:2]" {
+		t.Errorf("original changed: %s", got)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import "time"
+
+// Rough per-item overheads, in bytes, of the Go representation of a value.
+// SizeOf is an estimate for memory accounting, not an exact measurement.
+const (
+	sizeWord      = 8
+	sizeInterface = 16
+	sizeString    = 16
+	sizeMapEntry  = 48
+	sizeTreapNode = 64
+)
+
+// SizeOf estimates how many bytes v occupies. Sets and sorted sets keep a
+// running total of their member lengths, as do lists built by PushOnto, so
+// they are sized in O(1); objects and other lists are walked.
+func SizeOf(v interface{}) int64 {
+	switch val := v.(type) {
+	case int, float64, bool, NullValue:
+		return sizeInterface
+	case string:
+		return sizeInterface + sizeString + int64(len(val))
+	case []byte:
+		return sizeInterface + 3*sizeWord + int64(len(val))
+	case time.Time:
+		return sizeInterface + 3*sizeWord
+	case Decimal:
+		n := int64(sizeInterface + 4*sizeWord)
+		if val.unscaled != nil {
+			n += int64(len(val.unscaled.Bits())) * sizeWord
+		}
+		return n
+	case ObjectValue:
+		n := int64(sizeInterface + sizeMapEntry)
+		for name, item := range val.Data {
+			n += sizeMapEntry + sizeString + int64(len(name)) + SizeOf(item)
+		}
+		return n
+	case ListValue:
+		n := int64(sizeInterface + 3*sizeWord)
+		if val.tail != nil {
+			return n + val.size
+		}
+		for _, item := range val.Data {
+			n += SizeOf(item)
+		}
+		return n
+	case SetValue:
+		return sizeInterface + int64(val.Len())*(sizeTreapNode+sizeString) + int64(val.bytes)
+	case SortedSetValue:
+		return sizeInterface + int64(val.Len())*2*(sizeTreapNode+sizeString) + int64(val.bytes)*2
+	default:
+		return sizeInterface
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import "hash/fnv"
+
+// treapKey orders treap entries by score, then member. Trees keyed by member
+// alone leave score at 0.
+type treapKey struct {
+	score  float64
+	member string
+}
+
+func (a treapKey) less(b treapKey) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	return a.member < b.member
+}
+
+// treapNode is a node of a persistent treap: nodes are never modified once
+// built, so an update copies only the path it touches and every older root
+// stays valid. That keeps stored values read-only, as the rest of the store
+// expects, at O(log n) per change. size counts the subtree for rank queries.
+type treapNode struct {
+	key         treapKey
+	val         float64
+	prio        uint32
+	size        int
+	left, right *treapNode
+}
+
+func newTreapNode(key treapKey, val float64) *treapNode {
+	h := fnv.New32a()
+	h.Write([]byte(key.member))
+	return &treapNode{key: key, val: val, prio: h.Sum32(), size: 1}
+}
+
+func (n *treapNode) count() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *treapNode) withChildren(left, right *treapNode) *treapNode {
+	c := *n
+	c.left, c.right = left, right
+	c.size = 1 + left.count() + right.count()
+	return &c
+}
+
+// treapSplit divides n into the keys before k and the rest; with inclusive
+// set, k itself goes to the first half.
+func treapSplit(n *treapNode, k treapKey, inclusive bool) (*treapNode, *treapNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.key.less(k) || (inclusive && n.key == k) {
+		l, r := treapSplit(n.right, k, inclusive)
+		return n.withChildren(n.left, l), r
+	}
+	l, r := treapSplit(n.left, k, inclusive)
+	return l, n.withChildren(r, n.right)
+}
+
+// treapMerge joins two treaps where every key of a sorts before those of b.
+func treapMerge(a, b *treapNode) *treapNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.prio > b.prio:
+		return a.withChildren(a.left, treapMerge(a.right, b))
+	default:
+		return b.withChildren(treapMerge(a, b.left), b.right)
+	}
+}
+
+// treapPut returns n with k set to val, replacing any entry with the same key.
+func treapPut(n *treapNode, k treapKey, val float64) *treapNode {
+	l, r := treapSplit(n, k, false)
+	_, r = treapSplit(r, k, true)
+	return treapMerge(treapMerge(l, newTreapNode(k, val)), r)
+}
+
+// treapDelete returns n without k.
+func treapDelete(n *treapNode, k treapKey) *treapNode {
+	l, r := treapSplit(n, k, false)
+	_, r = treapSplit(r, k, true)
+	return treapMerge(l, r)
+}
+
+func treapGet(n *treapNode, k treapKey) (*treapNode, bool) {
+	for n != nil {
+		switch {
+		case k.less(n.key):
+			n = n.left
+		case n.key.less(k):
+			n = n.right
+		default:
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// treapRank counts the keys before k.
+func treapRank(n *treapNode, k treapKey) int {
+	rank := 0
+	for n != nil {
+		if n.key.less(k) {
+			rank += n.left.count() + 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return rank
+}
+
+// treapWalk visits the entries from rank from onwards in order until fn
+// returns false.
+func treapWalk(n *treapNode, from int, fn func(*treapNode) bool) bool {
+	if n == nil {
+		return true
+	}
+	left := n.left.count()
+	if from < left {
+		if !treapWalk(n.left, from, fn) {
+			return false
+		}
+	}
+	if from <= left {
+		if !fn(n) {
+			return false
+		}
+	}
+	return treapWalk(n.right, max(from-left-1, 0), fn)
+}
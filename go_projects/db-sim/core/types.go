@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type ObjectValue struct {
@@ -34,6 +36,12 @@ func (o ObjectValue) ToString() string {
 
 type ListValue struct {
 	Data []interface{}
+	// tail and size are set on lists built by PushOnto. tail is shared by
+	// the lists over one backing array and holds the length of the longest,
+	// the only one PushOnto may append to in place; size is what the items
+	// add to SizeOf, kept up as they are pushed.
+	tail *atomic.Int64
+	size int64
 }
 
 func (l ListValue) ToString() string {
@@ -43,3 +51,58 @@ func (l ListValue) ToString() string {
 	}
 	return "[" + strings.Join(parts, ",") + "]"
 }
+
+// Clone returns a deep copy of v, so the copy shares no maps or slices with
+// the original.
+func Clone(v interface{}) interface{} {
+	switch val := v.(type) {
+	case ObjectValue:
+		obj := NewObjectValue()
+		for k, item := range val.Data {
+			obj.Data[k] = Clone(item)
+		}
+		return obj
+	case ListValue:
+		list := ListValue{Data: make([]interface{}, len(val.Data))}
+		for i, item := range val.Data {
+			list.Data[i] = Clone(item)
+		}
+		return list
+	case []byte:
+		return append([]byte(nil), val...)
+	default:
+		return v
+	}
+}
+
+// KindOf names the kind of a stored value as reported by TYPE.
+func KindOf(v interface{}) string {
+	switch v.(type) {
+	case int:
+		return "scalar-int"
+	case float64:
+		return "scalar-float"
+	case string:
+		return "scalar-string"
+	case bool:
+		return "scalar-bool"
+	case NullValue:
+		return "scalar-null"
+	case time.Time:
+		return "scalar-timestamp"
+	case Decimal:
+		return "scalar-decimal"
+	case []byte:
+		return "scalar-bytes"
+	case ListValue:
+		return "list"
+	case ObjectValue:
+		return "object"
+	case SetValue:
+		return "set"
+	case SortedSetValue:
+		return "zset"
+	default:
+		return "unknown"
+	}
+}
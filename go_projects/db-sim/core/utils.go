@@ -1,18 +1,173 @@
 package core
 
-import "strconv"
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func ParsePrimitive(typeStr, rawVal string) interface{} {
+func ParsePrimitive(typeStr, rawVal string) (interface{}, error) {
 	switch typeStr {
 	case "INT":
-		v, _ := strconv.Atoi(rawVal)
-		return v
+		v, err := strconv.Atoi(rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid INT '%s'", ErrParse, rawVal)
+		}
+		return v, nil
 	case "FLOAT":
-		v, _ := strconv.ParseFloat(rawVal, 64)
-		return v
+		v, err := strconv.ParseFloat(rawVal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid FLOAT '%s'", ErrParse, rawVal)
+		}
+		return v, nil
 	case "STRING":
-		return rawVal
+		return rawVal, nil
+	case "BOOL":
+		v, err := strconv.ParseBool(rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid BOOL '%s'", ErrParse, rawVal)
+		}
+		return v, nil
+	case "NULL":
+		if !strings.EqualFold(rawVal, "null") {
+			return nil, fmt.Errorf("%w: NULL takes the value 'null', got '%s'", ErrParse, rawVal)
+		}
+		return NullValue{}, nil
+	case "TIMESTAMP":
+		v, err := time.Parse(time.RFC3339Nano, rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid RFC 3339 TIMESTAMP '%s'", ErrParse, rawVal)
+		}
+		return v, nil
+	case "DECIMAL":
+		v, err := ParseDecimal(rawVal)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "BYTES":
+		v, err := base64.StdEncoding.DecodeString(rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid base64 BYTES '%s'", ErrParse, rawVal)
+		}
+		return v, nil
 	default:
-		return nil
+		return nil, fmt.Errorf("%w '%s'", ErrBadType, typeStr)
+	}
+}
+
+// ParseValue reads one value of type typeStr from the front of tokens and
+// returns the tokens left over. Scalars take a single token; OBJECT and LIST
+// take a count followed by that many fields ("name TYPE ...") or elements
+// ("TYPE ..."), nested to any depth.
+func ParseValue(typeStr string, tokens []string) (interface{}, []string, error) {
+	switch typeStr {
+	case "OBJECT", "LIST":
+		if len(tokens) == 0 {
+			return nil, nil, ErrIncomplete
+		}
+		n, err := strconv.Atoi(tokens[0])
+		if err != nil || n < 0 {
+			return nil, nil, fmt.Errorf("%w: invalid %s size '%s'", ErrParse, typeStr, tokens[0])
+		}
+		if typeStr == "OBJECT" {
+			return ParseFields(n, tokens[1:])
+		}
+		return parseElems(n, tokens[1:])
+	default:
+		if len(tokens) == 0 {
+			return nil, nil, ErrIncomplete
+		}
+		v, err := ParsePrimitive(typeStr, tokens[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		return v, tokens[1:], nil
+	}
+}
+
+func ParseFields(n int, tokens []string) (ObjectValue, []string, error) {
+	obj := NewObjectValue()
+	for i := 0; i < n; i++ {
+		if len(tokens) < 2 {
+			return obj, nil, ErrIncomplete
+		}
+		name := tokens[0]
+		val, rest, err := ParseValue(tokens[1], tokens[2:])
+		if err != nil {
+			return obj, nil, err
+		}
+		obj.Data[name] = val
+		tokens = rest
+	}
+	return obj, tokens, nil
+}
+
+func parseElems(n int, tokens []string) (ListValue, []string, error) {
+	list := ListValue{Data: make([]interface{}, 0, n)}
+	for i := 0; i < n; i++ {
+		if len(tokens) == 0 {
+			return list, nil, ErrIncomplete
+		}
+		val, rest, err := ParseValue(tokens[0], tokens[1:])
+		if err != nil {
+			return list, nil, err
+		}
+		list.Data = append(list.Data, val)
+		tokens = rest
+	}
+	return list, tokens, nil
+}
+
+// ValueArgs renders v as the "TYPE ..." tokens ParseValue reads back.
+func ValueArgs(v interface{}) ([]string, error) {
+	switch val := v.(type) {
+	case int:
+		return []string{"INT", strconv.Itoa(val)}, nil
+	case float64:
+		return []string{"FLOAT", strconv.FormatFloat(val, 'f', -1, 64)}, nil
+	case string:
+		return []string{"STRING", val}, nil
+	case bool:
+		return []string{"BOOL", strconv.FormatBool(val)}, nil
+	case NullValue:
+		return []string{"NULL", "null"}, nil
+	case time.Time:
+		return []string{"TIMESTAMP", val.Format(time.RFC3339Nano)}, nil
+	case Decimal:
+		return []string{"DECIMAL", val.ToString()}, nil
+	case []byte:
+		return []string{"BYTES", base64.StdEncoding.EncodeToString(val)}, nil
+	case ObjectValue:
+		names := make([]string, 0, len(val.Data))
+		for name := range val.Data {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		args := []string{"OBJECT", strconv.Itoa(len(names))}
+		for _, name := range names {
+			field, err := ValueArgs(val.Data[name])
+			if err != nil {
+				return nil, err
+			}
+			args = append(append(args, name), field...)
+		}
+		return args, nil
+	case ListValue:
+		args := []string{"LIST", strconv.Itoa(len(val.Data))}
+		for _, item := range val.Data {
+			elem, err := ValueArgs(item)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, elem...)
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported value of type %T", ErrBadType, v)
 	}
 }
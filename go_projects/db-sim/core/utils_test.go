@@ -0,0 +1,104 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFields_Nested(t *testing.T) {
+	tokens := strings.Fields(`name STRING bob
+		address OBJECT 2 city STRING Paris zip INT 75001
+		phones LIST 3 STRING 555-1 OBJECT 1 kind STRING work LIST 1 INT 7
+		tail`)
+
+	obj, rest, err := ParseFields(3, tokens)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != "tail" {
+		t.Fatalf("unexpected rest %v", rest)
+	}
+	want := "{address:{city:Paris,zip:75001},name:bob,phones:[555-1,{kind:work},[7]]}"
+	if got := obj.ToString(); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseFields_Incomplete(t *testing.T) {
+	for _, def := range []string{"a OBJECT 2 b INT 1", "a LIST", "a LIST 2 INT 1", "a INT"} {
+		if _, _, err := ParseFields(1, strings.Fields(def)); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("%q: expected ErrIncomplete, got %v", def, err)
+		}
+	}
+}
+
+func TestParsePrimitive_Errors(t *testing.T) {
+	cases := []struct {
+		typ, raw string
+		want     error
+	}{
+		{"INT", "abc", ErrParse},
+		{"FLOAT", "1.2.3", ErrParse},
+		{"BOOL", "maybe", ErrParse},
+		{"NULL", "nil", ErrParse},
+		{"TIMESTAMP", "2024-13-01", ErrParse},
+		{"DECIMAL", "1.", ErrParse},
+		{"DECIMAL", "1e5", ErrParse},
+		{"DECIMAL", "-+5", ErrParse},
+		{"DECIMAL", "+-5", ErrParse},
+		{"BYTES", "not base64!", ErrParse},
+		{"UUID", "1", ErrBadType},
+	}
+	for _, c := range cases {
+		if _, err := ParsePrimitive(c.typ, c.raw); !errors.Is(err, c.want) {
+			t.Errorf("%s %s: expected %v, got %v", c.typ, c.raw, c.want, err)
+		}
+	}
+
+	if v, err := ParsePrimitive("INT", "-7"); err != nil || v != -7 {
+		t.Fatalf("INT -7: got %#v, %v", v, err)
+	}
+}
+
+func TestValueArgs_RoundTripsScalars(t *testing.T) {
+	cases := []struct{ typ, raw string }{
+		{"INT", "-7"},
+		{"FLOAT", "2.5"},
+		{"STRING", "bob"},
+		{"BOOL", "true"},
+		{"NULL", "null"},
+		{"TIMESTAMP", "2024-03-01T12:30:00.25+02:00"},
+		{"DECIMAL", "-0.0100"},
+		{"DECIMAL", "123456789012345678901234567890.5"},
+		{"BYTES", "aGVsbG8="},
+	}
+	for _, c := range cases {
+		v, err := ParsePrimitive(c.typ, c.raw)
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.typ, c.raw, err)
+		}
+		if got := FormatValue(v); got != c.raw {
+			t.Errorf("%s %s: formatted as %s", c.typ, c.raw, got)
+		}
+		args, err := ValueArgs(v)
+		if err != nil || len(args) != 2 || args[0] != c.typ || args[1] != c.raw {
+			t.Errorf("%s %s: ValueArgs gave %v, %v", c.typ, c.raw, args, err)
+		}
+	}
+}
+
+func TestValueArgs_ParseValueInverse(t *testing.T) {
+	tokens := strings.Fields("OBJECT 3 at TIMESTAMP 2024-03-01T00:00:00Z ok BOOL false tags LIST 2 DECIMAL 1.50 NULL null")
+	v, _, err := ParseValue(tokens[0], tokens[1:])
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	args, err := ValueArgs(v)
+	if err != nil {
+		t.Fatalf("args: %v", err)
+	}
+	if got := strings.Join(args, " "); got != strings.Join(tokens, " ") {
+		t.Fatalf("got %s", got)
+	}
+}
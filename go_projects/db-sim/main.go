@@ -1,87 +1,204 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dim4d/DbSim/command"
 	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/parser"
+	"github.com/dim4d/DbSim/persist"
+	"github.com/dim4d/DbSim/server"
 	"github.com/dim4d/DbSim/storage"
 )
 
+var (
+	aofPath     = flag.String("aof", "", "append-only command log; empty disables it")
+	appendFsync = flag.String("appendfsync", "everysec", "log fsync policy: always, everysec or no")
+	strictPush  = flag.Bool("strict-push", false, "make PUSH onto a non-list value an error instead of converting it")
+	strictAggr  = flag.Bool("strict-aggregates", false, "make SUM, AVG, MIN and MAX fail on non-numeric values instead of skipping them")
+	listenAddr  = flag.String("listen", "", "serve RESP clients on this TCP address instead of reading stdin")
+	dbFile      = flag.String("dbfile", command.DefaultSnapshotPath, "snapshot file used by SAVE and LOAD")
+	interactive = flag.Bool("interactive", false, "start the interactive shell even when stdin is not a terminal")
+	historyFile = flag.String("history", defaultHistoryPath(), "interactive shell history file; empty disables it")
+	maxMemory   = flag.String("maxmemory", "0", "limit on the estimated store size, such as 64mb; 0 means no limit")
+	maxPolicy   = flag.String("maxmemory-policy", "noeviction", "what to do over the limit: noeviction, allkeys-lru, allkeys-lfu or volatile-ttl")
+	keyHistory  = flag.Int("key-history", 0, "keep this many recent changes per key for HISTORY, GET ... AT and REVERT; 0 disables it")
+	engineName  = flag.String("engine", "hash", "storage engine: "+strings.Join(storage.EngineNames, ", "))
+	engineDir   = flag.String("engine-dir", "", "directory for the disk engine's page files; empty uses the system temp directory")
+)
+
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
+	flag.Parse()
+	if err := run(); err != nil {
+		log.Println("error:", err)
+		os.Exit(1)
+	}
+}
 
-	if !scanner.Scan() {
-		return
+// run does the work of main, returning instead of exiting so that the
+// deferred cleanup runs and its errors are reported too.
+func run() (err error) {
+	factory, err := storage.NewEngineFactory(*engineName, *engineDir)
+	if err != nil {
+		return err
+	}
+	tb, err := storage.NewTypeBoxWithEngine(storage.DefaultShards, factory)
+	if err != nil {
+		return err
 	}
-	qStr := scanner.Text()
-	q, _ := strconv.Atoi(qStr)
+	defer func() { err = errors.Join(err, tb.Close()) }()
 
-	tb := storage.NewTypeBox()
+	limit, err := storage.ParseMemorySize(*maxMemory)
+	if err != nil {
+		return err
+	}
+	evictionPolicy, err := storage.ParseEvictionPolicy(*maxPolicy)
+	if err != nil {
+		return err
+	}
+	tb.SetMaxMemory(limit, evictionPolicy)
+
+	// The executor is fully configured before the log is replayed through
+	// it, so replay obeys the same limits and settings as live commands.
+	exec := command.NewExecutor(tb)
+	exec.SetSnapshotPath(*dbFile)
+	exec.SetStrictPush(*strictPush)
+	exec.SetStrictAggregates(*strictAggr)
+	exec.SetHistoryDepth(*keyHistory)
+
+	if *aofPath != "" {
+		var aof *persist.AOF
+		if aof, err = openAOF(exec); err != nil {
+			return err
+		}
+		defer func() { err = errors.Join(err, aof.Close()) }()
+		exec.SetAOF(aof)
+	}
+	stopSweeper := exec.StartExpirySweeper(100 * time.Millisecond)
+	defer stopSweeper()
+
+	if *listenAddr != "" {
+		return serve(*listenAddr, exec)
+	}
+
+	if *interactive || isTerminal(os.Stdin) {
+		runREPL(os.Stdin, os.Stdout, exec, *historyFile)
+		return nil
+	}
+	runBatch(os.Stdin, os.Stdout, exec)
+	return nil
+}
+
+// openAOF replays the log named by -aof through exec and opens it for
+// appending.
+func openAOF(exec *command.Executor) (*persist.AOF, error) {
+	policy, err := persist.ParseFsyncPolicy(*appendFsync)
+	if err != nil {
+		return nil, err
+	}
+	return persist.OpenAOF(*aofPath, policy, func(args []string) error {
+		_, err := exec.Exec(args)
+		return err
+	})
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".dbsim_history")
+}
+
+func serve(addr string, exec *command.Executor) error {
+	srv := server.New(exec)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe(addr)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, server.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func runBatch(in io.Reader, out io.Writer, exec *command.Executor) {
+	p := parser.NewParser(in)
+	sess := exec.NewSession()
+
+	line, err := p.ReadLine()
+	if err != nil {
+		return
+	}
+	qStr := strings.TrimSpace(line)
+	q, err := strconv.Atoi(qStr)
+	if err != nil {
+		printReply(out, fmt.Errorf("%w: invalid command count '%s'", core.ErrParse, qStr))
+		return
+	}
 
 	for i := 0; i < q; i++ {
-		if !scanner.Scan() {
+		cmd, err := p.Next()
+		if err == io.EOF {
 			break
 		}
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
+		if err != nil {
+			printReply(out, err)
+			continue
+		}
+		if cmd.Empty() {
 			continue
 		}
 
-		cmd := parts[0]
-
-		switch cmd {
-		case "SET":
-			if len(parts) < 4 {
-				continue
-			}
-			key, typ, val := parts[1], parts[2], parts[3]
-			tb.SetScalar(key, typ, val)
-
-		case "OBJECT":
-			if len(parts) < 3 {
-				continue
-			}
-			key := parts[1]
-			n, _ := strconv.Atoi(parts[2])
-
-			newObj := core.NewObjectValue()
-
-			for j := 0; j < n; j++ {
-				scanner.Scan()
-				fieldLine := scanner.Text()
-				fParts := strings.Fields(fieldLine)
-				if len(fParts) < 3 {
-					continue
-				}
-				fName, fType, fVal := fParts[0], fParts[1], fParts[2]
-				newObj.Data[fName] = core.ParsePrimitive(fType, fVal)
-			}
-			tb.SaveObject(key, newObj)
-
-		case "PUSH":
-			if len(parts) < 4 {
-				continue
-			}
-			key, typ, val := parts[1], parts[2], parts[3]
-			tb.PushValue(key, typ, val)
-
-		case "MERGE":
-			if len(parts) < 3 {
-				continue
-			}
-			target, source := parts[1], parts[2]
-			tb.MergeObjects(target, source)
-
-		case "PRINT":
-			if len(parts) < 2 {
-				continue
-			}
-			key := parts[1]
-			tb.PrintKey(key)
+		reply, err := sess.Exec(cmd.Strings())
+		if err != nil {
+			printReply(out, err)
+			continue
+		}
+		printReply(out, reply)
+	}
+}
+
+func printReply(out io.Writer, reply interface{}) {
+	switch r := reply.(type) {
+	case nil:
+		fmt.Fprintln(out, "null")
+	case error:
+		fmt.Fprintln(out, "ERR", r)
+	case command.Status:
+	case []interface{}:
+		for _, item := range r {
+			printReply(out, item)
 		}
+	default:
+		fmt.Fprintln(out, r)
 	}
 }
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"github.com/dim4d/DbSim/core"
+)
+
+// Command is one parsed command. Args holds every token after the name,
+// with multi-line OBJECT and PUSH definitions already flattened onto it;
+// Value is the typed value of SET, PUSH, OBJECT, LSET and LREM when the
+// arguments contain a complete one.
+type Command struct {
+	Name  string
+	Args  []Token
+	Value Value
+	Pos   Pos
+}
+
+// Empty reports whether the command came from a blank or comment-only line.
+func (c *Command) Empty() bool {
+	return c.Name == ""
+}
+
+// Strings returns the command in the flat form command.Executor takes.
+func (c *Command) Strings() []string {
+	args := make([]string, 0, len(c.Args)+1)
+	args = append(args, c.Name)
+	for _, tok := range c.Args {
+		args = append(args, tok.Text)
+	}
+	return args
+}
+
+// Value is a node of a typed value: a Scalar, an Object or a List.
+type Value interface {
+	Pos() Pos
+	// Core returns the value as it is stored in a TypeBox.
+	Core() interface{}
+}
+
+type Scalar struct {
+	Type string
+	Text string
+	At   Pos
+	val  interface{}
+}
+
+func (s *Scalar) Pos() Pos          { return s.At }
+func (s *Scalar) Core() interface{} { return s.val }
+
+type Field struct {
+	Name  string
+	Value Value
+	At    Pos
+}
+
+type Object struct {
+	Fields []Field
+	At     Pos
+}
+
+func (o *Object) Pos() Pos { return o.At }
+
+func (o *Object) Core() interface{} {
+	obj := core.NewObjectValue()
+	for _, f := range o.Fields {
+		obj.Data[f.Name] = f.Value.Core()
+	}
+	return obj
+}
+
+type List struct {
+	Elems []Value
+	At    Pos
+}
+
+func (l *List) Pos() Pos { return l.At }
+
+func (l *List) Core() interface{} {
+	list := core.ListValue{Data: make([]interface{}, len(l.Elems))}
+	for i, elem := range l.Elems {
+		list.Data[i] = elem.Core()
+	}
+	return list
+}
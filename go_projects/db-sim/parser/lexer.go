@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// Pos is a 1-based line and column; columns count runes, not bytes.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("line %d, col %d", p.Line, p.Col)
+}
+
+// Token is one word of a command line. Quoted is set when any part of it was
+// double-quoted; the escapes have already been decoded.
+type Token struct {
+	Text   string
+	Quoted bool
+	Pos    Pos
+}
+
+// SyntaxError is an error tied to a position in the input. It unwraps to the
+// core error describing the problem, so errors.Is keeps working.
+type SyntaxError struct {
+	Pos Pos
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Pos.String() + ": " + e.Err.Error()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func errorAt(pos Pos, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos, Err: fmt.Errorf("%w: "+format, append([]interface{}{core.ErrParse}, args...)...)}
+}
+
+// Lex splits one line into tokens. Words are separated by whitespace; a
+// double-quoted part of a word may contain spaces and the escapes \" \\ \n
+// \r \t \0 \xHH and \uHHHH, and joins the text around it as in a shell, so
+// ("New York", reads as (New York,. A line whose first word starts with '#'
+// is a comment; anywhere else '#' is an ordinary character. Backslashes in
+// unquoted text are kept as written, so glob patterns need no extra
+// escaping.
+func Lex(src string, line int) ([]Token, error) {
+	var toks []Token
+	col := 1
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			col++
+			continue
+		}
+		if r == '#' && len(toks) == 0 {
+			return nil, nil
+		}
+
+		tok := Token{Pos: Pos{Line: line, Col: col}}
+		var sb strings.Builder
+		for i < len(src) {
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			if r != '"' {
+				sb.WriteString(src[i : i+size])
+				i += size
+				col++
+				continue
+			}
+			text, n, cols, err := lexQuoted(src[i:], Pos{Line: line, Col: col})
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(text)
+			tok.Quoted = true
+			i += n
+			col += cols
+		}
+		tok.Text = sb.String()
+		toks = append(toks, tok)
+	}
+	return toks, nil
+}
+
+// lexQuoted decodes the quoted string at the start of src and returns it
+// with the number of bytes and columns it spanned.
+func lexQuoted(src string, pos Pos) (string, int, int, error) {
+	var sb strings.Builder
+	col := 1
+	for i := 1; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch r {
+		case '"':
+			return sb.String(), i + size, col + 1, nil
+		case '\\':
+			at := Pos{Line: pos.Line, Col: pos.Col + col}
+			if i+1 >= len(src) {
+				return "", 0, 0, errorAt(pos, "unterminated string")
+			}
+			n, err := unescape(&sb, src[i+1:], at)
+			if err != nil {
+				return "", 0, 0, err
+			}
+			i += 1 + n
+			col += 1 + n
+		default:
+			sb.WriteString(src[i : i+size])
+			i += size
+			col++
+		}
+	}
+	return "", 0, 0, errorAt(pos, "unterminated string")
+}
+
+// unescape decodes the escape sequence after a backslash into sb and returns
+// how many bytes of src it consumed. Escapes are ASCII, so bytes and columns
+// agree.
+func unescape(sb *strings.Builder, src string, at Pos) (int, error) {
+	switch src[0] {
+	case '"', '\\':
+		sb.WriteByte(src[0])
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case '0':
+		sb.WriteByte(0)
+	case 'x':
+		v, ok := hexValue(src[1:], 2)
+		if !ok {
+			return 0, errorAt(at, "\\x needs two hex digits")
+		}
+		sb.WriteByte(byte(v))
+		return 3, nil
+	case 'u':
+		v, ok := hexValue(src[1:], 4)
+		if !ok {
+			return 0, errorAt(at, "\\u needs four hex digits")
+		}
+		sb.WriteRune(rune(v))
+		return 5, nil
+	default:
+		r, _ := utf8.DecodeRuneInString(src)
+		return 0, errorAt(at, "unknown escape '\\%c'", r)
+	}
+	return 1, nil
+}
+
+func hexValue(s string, n int) (int, bool) {
+	if len(s) < n {
+		return 0, false
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			v = v<<4 | int(c-'0')
+		case c >= 'a' && c <= 'f':
+			v = v<<4 | int(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			v = v<<4 | int(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return v, true
+}
+
+// Split lexes a single line and returns the token texts.
+func Split(line string) ([]string, error) {
+	toks, err := Lex(line, 1)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(toks))
+	for i, tok := range toks {
+		args[i] = tok.Text
+	}
+	return args, nil
+}
+
+// Quote returns s as a token Lex reads back unchanged: bare when that is
+// safe, double-quoted with escapes otherwise.
+func Quote(s string) string {
+	if isBare(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&sb, "\\x%02x", s[i])
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString("\\n")
+		case r == '\r':
+			sb.WriteString("\\r")
+		case r == '\t':
+			sb.WriteString("\\t")
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, "\\x%02x", r)
+		default:
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func isBare(s string) bool {
+	if s == "" || s[0] == '#' || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if r == '"' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Join quotes each argument as needed and joins them into one line.
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
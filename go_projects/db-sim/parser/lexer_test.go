@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func TestLex_QuotesAndEscapes(t *testing.T) {
+	toks, err := Lex(`SET k STRING "a b\t\"c\"\\ \x41é"`, 3)
+	if err != nil {
+		t.Fatalf("lex: %v", err)
+	}
+	want := []string{"SET", "k", "STRING", "a b\t\"c\"\\ Aé"}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens: %+v", len(toks), toks)
+	}
+	for i, w := range want {
+		if toks[i].Text != w {
+			t.Errorf("token %d: got %q, want %q", i, toks[i].Text, w)
+		}
+	}
+	if !toks[3].Quoted || toks[3].Pos != (Pos{Line: 3, Col: 14}) {
+		t.Fatalf("quoted token: %+v", toks[3])
+	}
+}
+
+func TestLex_OnlyALeadingHashIsAComment(t *testing.T) {
+	for _, src := range []string{"# SET k #x", "  #SET k #x"} {
+		if toks, err := Lex(src, 1); err != nil || len(toks) != 0 {
+			t.Errorf("%q: got %+v, %v", src, toks, err)
+		}
+	}
+	args, err := Split("SET k #x # y")
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(args) != 5 || args[2] != "#x" || args[3] != "#" {
+		t.Fatalf("got %q", args)
+	}
+}
+
+func TestLex_QuotedPartsJoinWords(t *testing.T) {
+	args, err := Split(`KEYS user\*  IN ("New York",Paris)`)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(args) != 4 || args[1] != `user\*` || args[3] != `(New York,Paris)` {
+		t.Fatalf("got %q", args)
+	}
+}
+
+func TestLex_ErrorPositions(t *testing.T) {
+	cases := []struct {
+		line string
+		pos  Pos
+	}{
+		{`SET k STRING "open`, Pos{1, 14}},
+		{`SET k STRING "bad \q"`, Pos{1, 19}},
+		{`SET k STRING "\x4"`, Pos{1, 15}},
+	}
+	for _, c := range cases {
+		_, err := Lex(c.line, 1)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Pos != c.pos || !errors.Is(err, core.ErrParse) {
+			t.Errorf("%s: got %v", c.line, err)
+		}
+	}
+}
+
+func TestQuote_RoundTrips(t *testing.T) {
+	for _, s := range []string{"plain", "", "two words", `"quoted"`, "#hash", `back\slash`, `mid"quote`, "line\nbreak\r\x00\x7f", "\xff\xfe", "naïve"} {
+		args, err := Split(Join([]string{"SET", s}))
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if len(args) != 2 || args[1] != s {
+			t.Errorf("%q: round trip gave %q", s, args)
+		}
+	}
+	if got := Quote("plain"); got != "plain" {
+		t.Fatalf("plain word was quoted: %s", got)
+	}
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+const maxLineLen = 16 << 20
+
+// LineFunc supplies input lines without their newline and returns io.EOF at
+// the end. continued is true while the lines of a multi-line OBJECT or PUSH
+// are being read, so interactive front ends can show a different prompt.
+type LineFunc func(continued bool) (string, error)
+
+// Parser turns lines of input into Commands, reading the continuation lines
+// of "OBJECT key n" and "PUSH key OBJECT|LIST n" definitions as it goes.
+type Parser struct {
+	read LineFunc
+	line int
+}
+
+func NewParser(r io.Reader) *Parser {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLen)
+	return NewLineParser(func(bool) (string, error) {
+		if scanner.Scan() {
+			return scanner.Text(), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	})
+}
+
+func NewLineParser(read LineFunc) *Parser {
+	return &Parser{read: read}
+}
+
+// Line returns the number of lines read so far.
+func (p *Parser) Line() int {
+	return p.line
+}
+
+// ReadLine returns the next raw line, for input that is not a command.
+func (p *Parser) ReadLine() (string, error) {
+	line, err := p.read(false)
+	if err != nil {
+		return "", err
+	}
+	p.line++
+	return line, nil
+}
+
+// Next parses the next command. It returns io.EOF at the end of the input
+// and an empty Command for a blank or comment-only line. After an error in a
+// multi-line definition the rest of the definition is still consumed, so the
+// following call starts on the next command.
+func (p *Parser) Next() (*Command, error) {
+	line, err := p.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	toks, err := Lex(line, p.line)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return &Command{Pos: Pos{Line: p.line, Col: 1}}, nil
+	}
+
+	cmd := &Command{Name: strings.ToUpper(toks[0].Text), Args: toks[1:], Pos: toks[0].Pos}
+	r := &defReader{p: p}
+	switch cmd.Name {
+	case "OBJECT":
+		if len(cmd.Args) == 2 {
+			if n, err := strconv.Atoi(cmd.Args[1].Text); err == nil {
+				cmd.Args = append(cmd.Args[:1], r.fields(cmd.Args[1], n)...)
+			}
+		}
+	case "PUSH":
+		if len(cmd.Args) == 3 && isContainer(cmd.Args[1]) {
+			cmd.Args = append(cmd.Args[:1], r.value(cmd.Args[1:], cmd.Args[1].Pos)...)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := cmd.parseValue(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// ParseLine parses a single self-contained line.
+func ParseLine(line string) (*Command, error) {
+	done := false
+	return NewLineParser(func(bool) (string, error) {
+		if done {
+			return "", io.EOF
+		}
+		done = true
+		return line, nil
+	}).Next()
+}
+
+func isContainer(tok Token) bool {
+	return tok.Text == "OBJECT" || tok.Text == "LIST"
+}
+
+// defReader consumes the continuation lines of a multi-line OBJECT or PUSH
+// and flattens them into tokens. A malformed line is recorded in err, but
+// the remaining lines of the definition are still consumed until the input
+// runs out.
+type defReader struct {
+	p    *Parser
+	err  error
+	done bool
+}
+
+func (r *defReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *defReader) line() ([]Token, Pos) {
+	line, err := r.p.read(true)
+	pos := Pos{Line: r.p.line + 1, Col: 1}
+	if err != nil {
+		if err == io.EOF {
+			err = &SyntaxError{Pos: pos, Err: core.ErrIncomplete}
+		}
+		r.fail(err)
+		r.done = true
+		return nil, pos
+	}
+	r.p.line++
+	toks, err := Lex(line, r.p.line)
+	if err != nil {
+		r.fail(err)
+	}
+	return toks, pos
+}
+
+// fields reads n "name TYPE value" lines; a field of type OBJECT or LIST is
+// followed by its own lines.
+func (r *defReader) fields(count Token, n int) []Token {
+	toks := []Token{count}
+	for j := 0; j < n && !r.done; j++ {
+		line, pos := r.line()
+		if line == nil && r.err != nil {
+			continue
+		}
+		if len(line) < 3 {
+			r.fail(&SyntaxError{Pos: pos, Err: fmt.Errorf("%w: field line needs 'name TYPE value'", core.ErrWrongArity)})
+			continue
+		}
+		toks = append(toks, line[0])
+		toks = append(toks, r.value(line[1:], line[1].Pos)...)
+	}
+	return toks
+}
+
+func (r *defReader) elems(count Token, n int) []Token {
+	toks := []Token{count}
+	for j := 0; j < n && !r.done; j++ {
+		line, pos := r.line()
+		if line == nil && r.err != nil {
+			continue
+		}
+		if len(line) < 2 {
+			r.fail(&SyntaxError{Pos: pos, Err: fmt.Errorf("%w: element line needs 'TYPE value'", core.ErrWrongArity)})
+			continue
+		}
+		toks = append(toks, r.value(line, pos)...)
+	}
+	return toks
+}
+
+func (r *defReader) value(toks []Token, pos Pos) []Token {
+	if len(toks) != 2 {
+		r.fail(&SyntaxError{Pos: pos, Err: fmt.Errorf("%w: expected 'TYPE value'", core.ErrWrongArity)})
+		return toks
+	}
+	if !isContainer(toks[0]) {
+		return toks
+	}
+	n, err := strconv.Atoi(toks[1].Text)
+	if err != nil || n < 0 {
+		r.fail(errorAt(toks[1].Pos, "invalid %s size '%s'", toks[0].Text, toks[1].Text))
+		return toks
+	}
+	if toks[0].Text == "OBJECT" {
+		return append(toks[:1], r.fields(toks[1], n)...)
+	}
+	return append(toks[:1], r.elems(toks[1], n)...)
+}
+
+// valueArg is the index in Args of the TYPE token for commands that take a
+// typed value; OBJECT is handled separately.
+var valueArg = map[string]int{"SET": 1, "PUSH": 1, "LSET": 2, "LREM": 2}
+
+// parseValue builds the typed Value, reporting bad types and literals at
+// their position. Values with missing tokens are left for the executor to
+// reject with its arity error.
+func (c *Command) parseValue() error {
+	var (
+		v   Value
+		err error
+	)
+	if c.Name == "OBJECT" {
+		if len(c.Args) < 2 {
+			return nil
+		}
+		v, _, err = parseContainer(Token{Text: "OBJECT", Pos: c.Args[1].Pos}, c.Args[1:])
+	} else if i, ok := valueArg[c.Name]; ok && len(c.Args) > i {
+		v, _, err = parseValue(c.Args[i], c.Args[i+1:])
+	}
+	if errors.Is(err, core.ErrIncomplete) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.Value = v
+	return nil
+}
+
+func parseValue(typ Token, rest []Token) (Value, []Token, error) {
+	if isContainer(typ) {
+		return parseContainer(typ, rest)
+	}
+	if len(rest) == 0 {
+		return nil, nil, core.ErrIncomplete
+	}
+	val, err := core.ParsePrimitive(typ.Text, rest[0].Text)
+	if err != nil {
+		pos := rest[0].Pos
+		if errors.Is(err, core.ErrBadType) {
+			pos = typ.Pos
+		}
+		return nil, nil, &SyntaxError{Pos: pos, Err: err}
+	}
+	return &Scalar{Type: typ.Text, Text: rest[0].Text, At: typ.Pos, val: val}, rest[1:], nil
+}
+
+func parseContainer(typ Token, rest []Token) (Value, []Token, error) {
+	if len(rest) == 0 {
+		return nil, nil, core.ErrIncomplete
+	}
+	n, err := strconv.Atoi(rest[0].Text)
+	if err != nil || n < 0 {
+		return nil, nil, errorAt(rest[0].Pos, "invalid %s size '%s'", typ.Text, rest[0].Text)
+	}
+	rest = rest[1:]
+
+	if typ.Text == "OBJECT" {
+		obj := &Object{At: typ.Pos}
+		for i := 0; i < n; i++ {
+			if len(rest) < 2 {
+				return nil, nil, core.ErrIncomplete
+			}
+			name := rest[0]
+			v, next, err := parseValue(rest[1], rest[2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			obj.Fields = append(obj.Fields, Field{Name: name.Text, Value: v, At: name.Pos})
+			rest = next
+		}
+		return obj, rest, nil
+	}
+
+	list := &List{At: typ.Pos}
+	for i := 0; i < n; i++ {
+		if len(rest) == 0 {
+			return nil, nil, core.ErrIncomplete
+		}
+		v, next, err := parseValue(rest[0], rest[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		list.Elems = append(list.Elems, v)
+		rest = next
+	}
+	return list, rest, nil
+}
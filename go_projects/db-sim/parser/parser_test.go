@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func TestParser_MultiLineObject(t *testing.T) {
+	p := NewParser(strings.NewReader(`OBJECT user 3
+name STRING "Ada Lovelace"
+address OBJECT 1
+city STRING London
+tags LIST 2
+STRING math
+INT 1815
+# a comment
+PRINT user
+`))
+
+	cmd, err := p.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	want := "OBJECT user 3 name STRING Ada Lovelace address OBJECT 1 city STRING London tags LIST 2 STRING math INT 1815"
+	if got := strings.Join(cmd.Strings(), " "); got != want {
+		t.Fatalf("got %s", got)
+	}
+	obj, ok := cmd.Value.(*Object)
+	if !ok || len(obj.Fields) != 3 || obj.Fields[2].At != (Pos{Line: 5, Col: 1}) {
+		t.Fatalf("unexpected value %#v", cmd.Value)
+	}
+	if got := core.FormatValue(cmd.Value.Core()); got != "{address:{city:London},name:Ada Lovelace,tags:[math,1815]}" {
+		t.Fatalf("core value %s", got)
+	}
+
+	if cmd, err = p.Next(); err != nil || !cmd.Empty() {
+		t.Fatalf("comment line: %+v, %v", cmd, err)
+	}
+	if cmd, err = p.Next(); err != nil || cmd.Name != "PRINT" || cmd.Pos.Line != 9 {
+		t.Fatalf("print: %+v, %v", cmd, err)
+	}
+	if _, err = p.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestParser_ValueErrorsHavePositions(t *testing.T) {
+	cases := []struct {
+		line string
+		want error
+		pos  Pos
+	}{
+		{"SET n INT abc", core.ErrParse, Pos{1, 11}},
+		{"SET n UUID 1", core.ErrBadType, Pos{1, 7}},
+		{"PUSH l LIST 2 INT 1 BOOL maybe", core.ErrParse, Pos{1, 26}},
+		{"OBJECT o 1 when TIMESTAMP yesterday", core.ErrParse, Pos{1, 27}},
+		{"LSET l 0 FLOAT x", core.ErrParse, Pos{1, 16}},
+	}
+	for _, c := range cases {
+		_, err := ParseLine(c.line)
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Pos != c.pos || !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v", c.line, err)
+		}
+	}
+
+	// Missing tokens are left to the executor's arity check.
+	if cmd, err := ParseLine("SET n INT"); err != nil || cmd.Value != nil {
+		t.Fatalf("incomplete SET: %+v, %v", cmd, err)
+	}
+}
+
+func TestParser_BadDefinitionIsConsumed(t *testing.T) {
+	p := NewParser(strings.NewReader("OBJECT o 2\nname\ninner OBJECT 1\nx INT 1\nPRINT o\n"))
+
+	_, err := p.Next()
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Pos.Line != 2 || !errors.Is(err, core.ErrWrongArity) {
+		t.Fatalf("expected arity error on line 2, got %v", err)
+	}
+	if cmd, err := p.Next(); err != nil || cmd.Name != "PRINT" {
+		t.Fatalf("next command: %+v, %v", cmd, err)
+	}
+}
+
+func TestParser_TruncatedDefinition(t *testing.T) {
+	p := NewParser(strings.NewReader("PUSH l LIST 3\nINT 1\n"))
+	if _, err := p.Next(); !errors.Is(err, core.ErrIncomplete) {
+		t.Fatalf("expected ErrIncomplete, got %v", err)
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package persist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/parser"
+	"github.com/dim4d/DbSim/storage"
+)
+
+type FsyncPolicy int
+
+const (
+	FsyncAlways FsyncPolicy = iota
+	FsyncEverySec
+	FsyncNo
+)
+
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch strings.ToLower(s) {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	default:
+		return 0, fmt.Errorf("unknown fsync policy %q", s)
+	}
+}
+
+type AOF struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	policy FsyncPolicy
+	dirty  bool
+	stop   chan struct{}
+	done   chan struct{}
+
+	// size is where the log ends; a failed append is cut back to it.
+	size int64
+	// broken is set once a failed append could not be cut back, and is
+	// returned by every later append.
+	broken error
+}
+
+// OpenAOF replays every command already in the log through replay and then
+// opens the log for appending. A torn last line left by a crash is cut off.
+func OpenAOF(path string, policy FsyncPolicy, replay func(args []string) error) (*AOF, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := replayLog(f, path, replay)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	a := &AOF{
+		path:   path,
+		file:   f,
+		size:   good,
+		policy: policy,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go a.syncLoop()
+	return a, nil
+}
+
+func replayLog(r io.Reader, path string, replay func(args []string) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var offset int64
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += int64(len(line))
+
+		args, err := parser.Split(line)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if err := replay(args); err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+}
+
+// Append logs one or more commands with a single write, so the commands of a
+// transaction reach the file together. If the write or its fsync fails, the
+// file is cut back to where it was, so a failed append leaves no partial
+// line for later appends to follow and the caller can undo the commands. If
+// the file cannot be cut back, the log is broken and refuses every later
+// append until a rewrite replaces it.
+func (a *AOF) Append(cmds ...[]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.broken != nil {
+		return a.broken
+	}
+
+	var sb strings.Builder
+	for _, args := range cmds {
+		sb.WriteString(parser.Join(args) + "\n")
+	}
+	n, err := a.file.WriteString(sb.String())
+	if err == nil && a.policy == FsyncAlways {
+		err = a.file.Sync()
+	}
+	if err != nil {
+		if terr := a.file.Truncate(a.size); terr != nil {
+			a.broken = fmt.Errorf("append-only file left damaged: %w", errors.Join(err, terr))
+			return a.broken
+		}
+		return err
+	}
+	a.size += int64(n)
+	if a.policy != FsyncAlways {
+		a.dirty = true
+	}
+	return nil
+}
+
+func (a *AOF) syncLoop() {
+	defer close(a.done)
+	if a.policy != FsyncEverySec {
+		<-a.stop
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.dirty {
+				a.file.Sync()
+				a.dirty = false
+			}
+			a.mu.Unlock()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Rewrite replaces the log with the shortest command sequence that rebuilds
+// the current contents of tb.
+func (a *AOF) Rewrite(tb *storage.TypeBox) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var keys []string
+	tb.Range(func(key string, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+
+	tmpPath := a.path + ".rewrite"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, info := range tb.Indexes() {
+		w.WriteString(parser.Join([]string{"INDEX", "CREATE", info.Name, "ON", info.Field}) + "\n")
+	}
+	for _, key := range keys {
+		val, _ := tb.Peek(key)
+		cmds, err := RewriteCommands(key, val)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		if deadline, ok := tb.Expiry(key); ok {
+			cmds = append(cmds, []string{"PEXPIREAT", key, strconv.FormatInt(deadline.UnixMilli(), 10)})
+		}
+		for _, args := range cmds {
+			w.WriteString(parser.Join(args) + "\n")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, a.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	a.file.Close()
+	a.file, err = os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	a.size, a.dirty = info.Size(), false
+	if err != nil {
+		a.broken = fmt.Errorf("append-only file not reopened: %w", err)
+		return a.broken
+	}
+	a.broken = nil
+	return nil
+}
+
+func (a *AOF) Close() error {
+	close(a.stop)
+	<-a.done
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// RewriteCommands returns the commands that build val under key from
+// nothing, leaving out its expiry.
+func RewriteCommands(key string, val interface{}) ([][]string, error) {
+	switch v := val.(type) {
+	case core.ObjectValue:
+		args, err := core.ValueArgs(v)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		return [][]string{append([]string{"OBJECT", key}, args[1:]...)}, nil
+
+	case core.ListValue:
+		var cmds [][]string
+		for _, item := range v.Data {
+			args, err := core.ValueArgs(item)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			cmds = append(cmds, append([]string{"PUSH", key}, args...))
+		}
+		return cmds, nil
+
+	case core.SetValue:
+		return [][]string{append([]string{"SADD", key}, v.Members()...)}, nil
+
+	case core.SortedSetValue:
+		args := []string{"ZADD", key}
+		for _, e := range v.Entries() {
+			args = append(args, strconv.FormatFloat(e.Score, 'g', -1, 64), e.Member)
+		}
+		return [][]string{args}, nil
+
+	default:
+		args, err := core.ValueArgs(val)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		return [][]string{append([]string{"SET", key}, args...)}, nil
+	}
+}
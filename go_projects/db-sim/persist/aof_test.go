@@ -0,0 +1,147 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func collect(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	var got [][]string
+	aof, err := OpenAOF(path, FsyncAlways, func(args []string) error {
+		got = append(got, args)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { aof.Close() })
+	return got
+}
+
+func TestAOF_AppendAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+
+	aof, err := OpenAOF(path, FsyncAlways, func([]string) error { return nil })
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	aof.Append([]string{"SET", "a", "INT", "1"})
+	aof.Append([]string{"PUSH", "a", "FLOAT", "2.5"})
+	if err := aof.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	got := collect(t, path)
+	if len(got) != 2 || strings.Join(got[1], " ") != "PUSH a FLOAT 2.5" {
+		t.Fatalf("unexpected replay: %v", got)
+	}
+}
+
+func TestAOF_QuotesArgumentsWithSpaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+
+	aof, err := OpenAOF(path, FsyncAlways, func([]string) error { return nil })
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	aof.Append([]string{"SET", "note", "STRING", "two words\nand \"quotes\""})
+	if err := aof.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if want := `SET note STRING "two words\nand \"quotes\""` + "\n"; string(data) != want {
+		t.Fatalf("log line %q", data)
+	}
+	got := collect(t, path)
+	if len(got) != 1 || len(got[0]) != 4 || got[0][3] != "two words\nand \"quotes\"" {
+		t.Fatalf("unexpected replay: %q", got)
+	}
+}
+
+func TestAOF_TornTailIsDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+	if err := os.WriteFile(path, []byte("SET a INT 1\nSET b IN"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, path)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 command, got %v", got)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "SET a INT 1\n" {
+		t.Fatalf("torn tail not truncated: %q", data)
+	}
+}
+
+func TestAOF_Rewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+
+	tb := storage.NewTypeBox()
+	tb.SetScalar("n", "INT", "1")
+	tb.SetScalar("n", "INT", "2")
+	obj := core.NewObjectValue()
+	obj.Data["name"] = "bob"
+	tb.SaveObject("u", obj)
+	tb.PushValue("u", "FLOAT", "1.5")
+	tb.Put("s", core.NewSetValue("b", "a"))
+	tb.Put("z", core.NewSortedSetValue(core.ZEntry{Member: "x", Score: 2}, core.ZEntry{Member: "y", Score: 1e-7}))
+
+	aof, err := OpenAOF(path, FsyncNo, func([]string) error { return nil })
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := aof.Rewrite(tb); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	aof.Close()
+
+	data, _ := os.ReadFile(path)
+	want := "SET n INT 2\nSADD s a b\nPUSH u OBJECT 1 name STRING bob\nPUSH u FLOAT 1.5\nZADD z 1e-07 y 2 x\n"
+	if string(data) != want {
+		t.Fatalf("unexpected log:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+// TestAOF_FailedAppend swaps in a read-only handle so both the write and the
+// truncate that would undo it fail: the log must then refuse further appends
+// rather than write after whatever the failed one left behind.
+func TestAOF_FailedAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.aof")
+
+	aof, err := OpenAOF(path, FsyncAlways, func([]string) error { return nil })
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := aof.Append([]string{"SET", "a", "INT", "1"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	rw := aof.file
+	if aof.file, err = os.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := aof.Append([]string{"SET", "b", "INT", "2"}); err == nil {
+		t.Fatal("append through a read-only handle succeeded")
+	}
+	aof.file.Close()
+	aof.file = rw
+	if err := aof.Append([]string{"SET", "c", "INT", "3"}); err == nil {
+		t.Fatal("append after an unrepaired failure succeeded")
+	}
+	aof.Close()
+
+	got := collect(t, path)
+	if len(got) != 1 || strings.Join(got[0], " ") != "SET a INT 1" {
+		t.Fatalf("unexpected replay: %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+// ExportJSON writes every key of tb as one indented JSON object. Expiry
+// times are not part of the export.
+func ExportJSON(path string, tb *storage.TypeBox) error {
+	doc := make(map[string]interface{})
+	var err error
+	tb.Range(func(key string, val interface{}) bool {
+		var tree interface{}
+		if tree, err = core.JSONTree(val); err != nil {
+			err = fmt.Errorf("key %s: %w", key, err)
+			return false
+		}
+		doc[key] = tree
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// ImportJSON reads a file written by ExportJSON, or any JSON object, and
+// returns its members as stored values keyed by name.
+func ImportJSON(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := core.FromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	obj, ok := v.(core.ObjectValue)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: top level must be a JSON object", path, core.ErrWrongKind)
+	}
+	return obj.Data, nil
+}
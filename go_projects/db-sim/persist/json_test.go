@@ -0,0 +1,48 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestJSON_ExportImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.json")
+
+	tb := storage.NewTypeBox()
+	tb.SetScalar("n", "INT", "2")
+	obj := core.NewObjectValue()
+	obj.Data["name"] = "bob"
+	obj.Data["tags"] = core.ListValue{Data: []interface{}{1, 2.5}}
+	tb.SaveObject("u", obj)
+
+	if err := ExportJSON(path, tb); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "{\n  \"n\": 2,\n  \"u\": {\n    \"name\": \"bob\",\n    \"tags\": [\n      1,\n      2.5\n    ]\n  }\n}\n"
+	if string(data) != want {
+		t.Fatalf("export wrote:\n%s", data)
+	}
+
+	values, err := ImportJSON(path)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(values) != 2 || values["n"] != 2 || core.FormatValue(values["u"]) != "{name:bob,tags:[1,2.5]}" {
+		t.Fatalf("import gave %#v", values)
+	}
+}
+
+func TestJSON_ImportNeedsObject(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.json")
+	os.WriteFile(path, []byte("[1, 2]"), 0o644)
+
+	if _, err := ImportJSON(path); !errors.Is(err, core.ErrWrongKind) {
+		t.Fatalf("expected ErrWrongKind, got %v", err)
+	}
+}
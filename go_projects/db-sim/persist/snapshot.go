@@ -0,0 +1,208 @@
+package persist
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+const (
+	snapshotMagic   = "TBOX"
+	snapshotVersion = 4
+)
+
+var (
+	ErrBadSnapshot = errors.New("not a snapshot file")
+	ErrChecksum    = errors.New("snapshot checksum mismatch")
+)
+
+// Snapshot layout: magic, uint16 version, uvarint key count, then (key,
+// expiry, value in the core.AppendValue encoding) entries, followed by a
+// CRC-32 of everything before it. The expiry is a varint of Unix
+// milliseconds, 0 for none; version 1 files have no expiry field. Version 3
+// added the BOOL, NULL, TIMESTAMP, DECIMAL and BYTES tags; timestamps and
+// decimals are stored as their canonical text so no precision or offset is
+// lost. Version 4 added SET (a member count and the members) and ZSET (a
+// count and member, score pairs).
+func SaveSnapshot(path string, tb *storage.TypeBox) error {
+	var keys []string
+	tb.Range(func(key string, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+
+	buf := []byte(snapshotMagic)
+	buf = binary.LittleEndian.AppendUint16(buf, snapshotVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, key := range keys {
+		val, _ := tb.Peek(key)
+		buf = appendString(buf, key)
+		var expiry int64
+		if deadline, ok := tb.Expiry(key); ok {
+			expiry = deadline.UnixMilli()
+		}
+		buf = binary.AppendVarint(buf, expiry)
+		var err error
+		if buf, err = core.AppendValue(buf, val); err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// LoadSnapshot decodes the snapshot at path into a fresh TypeBox. Nothing is
+// returned unless the whole file checks out.
+func LoadSnapshot(path string) (*storage.TypeBox, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(snapshotMagic)+2+4 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, ErrChecksum
+	}
+
+	d := &decoder{buf: body[len(snapshotMagic):]}
+	version := d.uint16()
+	if version < 1 || version > snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	tb := storage.NewTypeBox()
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		key := d.string()
+		var expiry int64
+		if version >= 2 {
+			expiry = d.varint()
+		}
+		val := d.value()
+		if d.err == nil {
+			tb.Put(key, val)
+			if expiry != 0 {
+				tb.ExpireAt(key, time.UnixMilli(expiry))
+			}
+		}
+	}
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = errors.New("trailing data")
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, d.err)
+	}
+	return tb, nil
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+	d.buf = nil
+}
+
+func (d *decoder) take(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.buf)) < n {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	b := d.take(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail("bad length")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail("bad integer")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.take(d.uvarint()))
+}
+
+func (d *decoder) value() interface{} {
+	if d.err != nil {
+		return nil
+	}
+	v, rest, err := core.DecodeValue(d.buf)
+	if err != nil {
+		d.fail("%v", err)
+		return nil
+	}
+	d.buf = rest
+	return v
+}
@@ -0,0 +1,120 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestSnapshot_RoundTripKeepsTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.tbox")
+
+	tb := storage.NewTypeBox()
+	tb.SetScalar("i", "INT", "5")
+	tb.SetScalar("f", "FLOAT", "5")
+	obj := core.NewObjectValue()
+	obj.Data["name"] = "bob"
+	obj.Data["tags"] = core.ListValue{Data: []interface{}{1, 2.5, "x"}}
+	tb.SaveObject("u", obj)
+
+	if err := SaveSnapshot(path, tb); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if v, _ := loaded.Get("i"); v != 5 {
+		t.Fatalf("i: got %#v", v)
+	}
+	if v, _ := loaded.Get("f"); v != 5.0 {
+		t.Fatalf("f: got %#v", v)
+	}
+	v, _ := loaded.Get("u")
+	if got := core.FormatValue(v); got != "{name:bob,tags:[1,2.5,x]}" {
+		t.Fatalf("u: got %s", got)
+	}
+}
+
+func TestSnapshot_RoundTripKeepsRichScalars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.tbox")
+
+	tb := storage.NewTypeBox()
+	want := map[string]string{}
+	for key, def := range map[string][2]string{
+		"b": {"BOOL", "true"},
+		"n": {"NULL", "null"},
+		"t": {"TIMESTAMP", "2024-03-01T12:30:00.123456789-05:00"},
+		"d": {"DECIMAL", "-12.3400"},
+		"y": {"BYTES", "AAEC/w=="},
+	} {
+		if err := tb.SetScalar(key, def[0], def[1]); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+		want[key] = def[1]
+	}
+
+	if err := SaveSnapshot(path, tb); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for key, raw := range want {
+		v, _ := loaded.Get(key)
+		if got := core.FormatValue(v); got != raw {
+			t.Errorf("%s: got %s, want %s", key, got, raw)
+		}
+	}
+}
+
+func TestSnapshot_RejectsDamage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.tbox")
+
+	tb := storage.NewTypeBox()
+	tb.SetScalar("s", "STRING", "hello")
+	if err := SaveSnapshot(path, tb); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-6] ^= 0xff
+	os.WriteFile(path, corrupt, 0o644)
+	if _, err := LoadSnapshot(path); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("corrupted: expected ErrChecksum, got %v", err)
+	}
+
+	os.WriteFile(path, data[:len(data)-3], 0o644)
+	if _, err := LoadSnapshot(path); err == nil {
+		t.Fatal("truncated: expected an error")
+	}
+}
+
+func TestSnapshot_RoundTripKeepsSets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.tbox")
+
+	tb := storage.NewTypeBox()
+	tb.Put("s", core.NewSetValue("b", "a"))
+	tb.Put("z", core.NewSortedSetValue(core.ZEntry{Member: "x", Score: 2}, core.ZEntry{Member: "y", Score: -1.5}))
+
+	if err := SaveSnapshot(path, tb); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for key, want := range map[string]string{"s": "#{a,b}", "z": "#[y:-1.5,x:2]"} {
+		v, _ := loaded.Get(key)
+		if got := core.FormatValue(v); got != want {
+			t.Errorf("%s: got %s, want %s", key, got, want)
+		}
+	}
+}
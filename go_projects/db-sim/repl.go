@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dim4d/DbSim/command"
+	"github.com/dim4d/DbSim/parser"
+)
+
+const (
+	prompt         = "dbsim> "
+	continuePrompt = "  ...> "
+	maxHistory     = 1000
+)
+
+// isTerminal reports whether f is a character device, which for stdin means
+// a person is typing rather than a script being piped in.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// repl is the interactive shell. Besides the store's commands it knows HELP,
+// QUIT/EXIT, ".history", "!!" and "!n" to rerun an earlier command, and
+// ".timing on|off".
+type repl struct {
+	out      io.Writer
+	sess     *command.Session
+	history  []string
+	histFile *os.File
+	timing   bool
+}
+
+func runREPL(in io.Reader, out io.Writer, exec *command.Executor, historyPath string) {
+	r := &repl{out: out, sess: exec.NewSession(), timing: true}
+	if historyPath != "" {
+		r.loadHistory(historyPath)
+		if f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err == nil {
+			r.histFile = f
+			defer f.Close()
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	p := parser.NewLineParser(func(continued bool) (string, error) {
+		if continued {
+			fmt.Fprint(out, continuePrompt)
+		} else {
+			fmt.Fprint(out, prompt)
+		}
+		if scanner.Scan() {
+			return scanner.Text(), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	})
+
+	fmt.Fprintln(out, "DbSim interactive shell. Type HELP for commands, QUIT to leave.")
+	for {
+		cmd, err := p.Next()
+		if err == io.EOF {
+			fmt.Fprintln(out)
+			return
+		}
+		if err != nil {
+			r.print(err, 0)
+			continue
+		}
+		if cmd.Empty() {
+			continue
+		}
+		if !r.dispatch(cmd) {
+			return
+		}
+	}
+}
+
+// dispatch runs one command and returns false when the shell should exit.
+func (r *repl) dispatch(cmd *parser.Command) bool {
+	switch {
+	case cmd.Name == "QUIT" || cmd.Name == "EXIT":
+		return false
+	case cmd.Name == "HELP":
+		r.help(cmd.Strings()[1:])
+		return true
+	case cmd.Name == ".HISTORY":
+		for i, line := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, line)
+		}
+		return true
+	case cmd.Name == ".TIMING":
+		args := cmd.Strings()
+		if len(args) != 2 || !strings.EqualFold(args[1], "on") && !strings.EqualFold(args[1], "off") {
+			r.print(fmt.Errorf("usage: .timing on|off"), 0)
+			return true
+		}
+		r.timing = strings.EqualFold(args[1], "on")
+		return true
+	case strings.HasPrefix(cmd.Name, "!"):
+		line, err := r.recall(cmd.Name)
+		if err != nil {
+			r.print(err, 0)
+			return true
+		}
+		fmt.Fprintln(r.out, line)
+		if cmd, err = parser.ParseLine(line); err != nil {
+			r.print(err, 0)
+			return true
+		}
+		return r.dispatch(cmd)
+	}
+
+	args := cmd.Strings()
+	r.remember(parser.Join(args))
+
+	start := time.Now()
+	reply, err := r.sess.Exec(args)
+	elapsed := time.Since(start)
+	if err != nil {
+		reply = err
+	}
+	r.print(reply, 0)
+	if r.timing {
+		fmt.Fprintf(r.out, "(%s)\n", elapsed.Round(time.Microsecond))
+	}
+	return true
+}
+
+func (r *repl) help(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(r.out, "Commands:", strings.Join(command.CommandNames(), " "))
+		fmt.Fprintln(r.out, "Shell: HELP [command], QUIT, .history, !! / !n to rerun, .timing on|off")
+		fmt.Fprintln(r.out, `Strings with spaces go in double quotes: SET s STRING "hello world"`)
+		return
+	}
+	h, ok := command.LookupHelp(args[0])
+	if !ok {
+		r.print(fmt.Errorf("%w '%s'", command.ErrUnknownCommand, args[0]), 0)
+		return
+	}
+	fmt.Fprintln(r.out, h.Usage)
+	fmt.Fprintln(r.out, "  "+h.Summary)
+}
+
+// recall resolves "!!" and "!n" against the history.
+func (r *repl) recall(ref string) (string, error) {
+	if len(r.history) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if ref == "!!" {
+		return r.history[len(r.history)-1], nil
+	}
+	n, err := strconv.Atoi(ref[1:])
+	if err != nil || n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("no history entry '%s'", ref)
+	}
+	return r.history[n-1], nil
+}
+
+func (r *repl) remember(line string) {
+	r.history = append(r.history, line)
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+	if r.histFile != nil {
+		fmt.Fprintln(r.histFile, line)
+	}
+}
+
+func (r *repl) loadHistory(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+// print shows a reply the way redis-cli does: typed scalars and numbered
+// array elements, indented for nested arrays.
+func (r *repl) print(reply interface{}, indent int) {
+	switch v := reply.(type) {
+	case nil:
+		fmt.Fprintln(r.out, "(nil)")
+	case error:
+		fmt.Fprintln(r.out, "(error) ERR", v)
+	case command.Status:
+		fmt.Fprintln(r.out, v)
+	case int:
+		fmt.Fprintf(r.out, "(integer) %d\n", v)
+	case []interface{}:
+		if len(v) == 0 {
+			fmt.Fprintln(r.out, "(empty array)")
+			return
+		}
+		width := len(strconv.Itoa(len(v)))
+		for i, item := range v {
+			if i > 0 {
+				fmt.Fprint(r.out, strings.Repeat(" ", indent))
+			}
+			label := fmt.Sprintf("%*d) ", width, i+1)
+			fmt.Fprint(r.out, label)
+			r.print(item, indent+len(label))
+		}
+	default:
+		fmt.Fprintln(r.out, v)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dim4d/DbSim/command"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func TestREPL_MultiLineHistoryAndHelp(t *testing.T) {
+	in := strings.NewReader(`.timing off
+OBJECT u 1
+name STRING "Ada L"
+PRINT u
+!1
+HELP LLEN
+QUIT
+PRINT never
+`)
+	var out bytes.Buffer
+	histPath := filepath.Join(t.TempDir(), "history")
+	runREPL(in, &out, command.NewExecutor(storage.NewTypeBox()), histPath)
+
+	want := strings.Join([]string{
+		"DbSim interactive shell. Type HELP for commands, QUIT to leave.",
+		prompt + prompt + continuePrompt + "OK",
+		prompt + "{name:Ada L}",
+		prompt + `OBJECT u 1 name STRING "Ada L"`,
+		"OK",
+		prompt + "LLEN key|path",
+		"  Return the length of a list.",
+		prompt,
+	}, "\n")
+	if got := out.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var again bytes.Buffer
+	runREPL(strings.NewReader(".timing off\n!2\n"), &again, command.NewExecutor(storage.NewTypeBox()), histPath)
+	if !strings.Contains(again.String(), prompt+"PRINT u\n(nil)\n") {
+		t.Fatalf("history was not reloaded:\n%s", again.String())
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dim4d/DbSim/command"
+	"github.com/dim4d/DbSim/core"
+	"github.com/dim4d/DbSim/parser"
+	"github.com/dim4d/DbSim/storage"
+)
+
+var errSubscriberMode = errors.New("only SUBSCRIBE, PSUBSCRIBE, UNSUBSCRIBE, PUNSUBSCRIBE, PING and QUIT are allowed while subscribed")
+
+// subscriberMode serves a connection from its first SUBSCRIBE or PSUBSCRIBE
+// on. Messages are written by a second goroutine as they arrive, so w is
+// shared under a mutex. It returns true once the client has unsubscribed
+// from everything and goes back to ordinary commands, false when the
+// connection should close.
+func (s *Server) subscriberMode(r *bufio.Reader, w *bufio.Writer, args []string) bool {
+	sub := s.exec.Subscribe(storage.DefaultEventBuffer)
+	var wmu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range sub.C() {
+			wmu.Lock()
+			writeReply(w, messageReply(msg))
+			if len(sub.C()) == 0 {
+				w.Flush()
+			}
+			wmu.Unlock()
+		}
+	}()
+	defer func() {
+		sub.Close()
+		<-done
+	}()
+
+	for {
+		name := strings.ToUpper(args[0])
+		var replies []interface{}
+		switch name {
+		case "SUBSCRIBE", "PSUBSCRIBE":
+			if len(args) == 1 {
+				replies = []interface{}{fmt.Errorf("%w for '%s'", core.ErrWrongArity, name)}
+				break
+			}
+			replies = subscriptionChange(sub, name, args[1:])
+		case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+			replies = subscriptionChange(sub, name, args[1:])
+		case "PING":
+			replies = []interface{}{command.Status("PONG")}
+		case "QUIT":
+			replies = []interface{}{command.OK}
+		default:
+			replies = []interface{}{errSubscriberMode}
+		}
+
+		wmu.Lock()
+		for _, reply := range replies {
+			writeReply(w, reply)
+		}
+		err := w.Flush()
+		wmu.Unlock()
+		if err != nil || name == "QUIT" {
+			return false
+		}
+		if strings.HasSuffix(name, "UNSUBSCRIBE") && subscriptionCount(sub) == 0 {
+			return true
+		}
+
+		for {
+			if args, err = readCommand(r); err != nil {
+				var syntaxErr *parser.SyntaxError
+				if !errors.As(err, &syntaxErr) {
+					return false
+				}
+				wmu.Lock()
+				writeReply(w, err)
+				w.Flush()
+				wmu.Unlock()
+				continue
+			}
+			if len(args) > 0 {
+				break
+			}
+		}
+	}
+}
+
+// subscriptionChange applies one (P)(UN)SUBSCRIBE and builds its replies, one
+// per channel or pattern with the subscription count after it, as Redis
+// does. An UNSUBSCRIBE without arguments drops everything of its kind.
+func subscriptionChange(sub *storage.Subscription, name string, names []string) []interface{} {
+	kind := strings.ToLower(name)
+	pattern := strings.HasPrefix(name, "P")
+	if len(names) == 0 && strings.HasSuffix(name, "UNSUBSCRIBE") {
+		channels, patterns := sub.Channels()
+		if names = channels; pattern {
+			names = patterns
+		}
+		if len(names) == 0 {
+			return []interface{}{[]interface{}{kind, nil, subscriptionCount(sub)}}
+		}
+	}
+
+	replies := make([]interface{}, 0, len(names))
+	for _, n := range names {
+		var count int
+		switch name {
+		case "SUBSCRIBE":
+			count = sub.Subscribe(n)
+		case "PSUBSCRIBE":
+			count = sub.PSubscribe(n)
+		case "UNSUBSCRIBE":
+			count = sub.Unsubscribe(n)
+		default:
+			count = sub.PUnsubscribe(n)
+		}
+		replies = append(replies, []interface{}{kind, n, count})
+	}
+	return replies
+}
+
+func subscriptionCount(sub *storage.Subscription) int {
+	channels, patterns := sub.Channels()
+	return len(channels) + len(patterns)
+}
+
+func messageReply(msg storage.Message) []interface{} {
+	if msg.Pattern != "" {
+		return []interface{}{"pmessage", msg.Pattern, msg.Channel, msg.Payload}
+	}
+	return []interface{}{"message", msg.Channel, msg.Payload}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/command"
+	"github.com/dim4d/DbSim/storage"
+)
+
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := New(command.NewExecutor(storage.NewTypeBox()))
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, ln.Addr().String()
+}
+
+func encode(args ...string) string {
+	var sb strings.Builder
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		sb.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	return sb.String()
+}
+
+func TestServer_RESPRoundTrip(t *testing.T) {
+	_, addr := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte(encode("SET", "a", "INT", "5") + encode("push", "a", "FLOAT", "1.5") +
+		encode("PRINT", "a") + encode("PRINT", "missing") + encode("NOPE")))
+
+	r := bufio.NewReader(conn)
+	want := []string{"+OK", "+OK", "$7", "[5,1.5]", "$-1", "-ERR unknown command 'NOPE'"}
+	for _, w := range want {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); got != w {
+			t.Fatalf("got %q, want %q", got, w)
+		}
+	}
+}
+
+func TestServer_ShutdownClosesClients(t *testing.T) {
+	srv, addr := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PING\r\n"))
+	r := bufio.NewReader(conn)
+	if line, _ := r.ReadString('\n'); line != "+PONG\r\n" {
+		t.Fatalf("unexpected ping reply %q", line)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatal("expected connection to be closed")
+	}
+}
+
+func TestServer_InlineQuotedStrings(t *testing.T) {
+	_, addr := startServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("SET greeting STRING \"hello, \\\"world\\\"\"\r\nSET bad STRING \"open\r\nPRINT greeting\r\n"))
+
+	r := bufio.NewReader(conn)
+	want := []string{"+OK", "-ERR line 1, col 16: parse error: unterminated string", "$14", `hello, "world"`}
+	for _, w := range want {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); got != w {
+			t.Fatalf("got %q, want %q", got, w)
+		}
+	}
+}
+
+func expectLines(t *testing.T, r *bufio.Reader, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if got := strings.TrimRight(line, "\r\n"); got != w {
+			t.Fatalf("got %q, want %q", got, w)
+		}
+	}
+}
+
+func TestServer_KeyspaceEvents(t *testing.T) {
+	_, addr := startServer(t)
+
+	sub, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer sub.Close()
+	sr := bufio.NewReader(sub)
+	sub.Write([]byte(encode("PSUBSCRIBE", "__keyevent__:*")))
+	expectLines(t, sr, "*3", "$10", "psubscribe", "$14", "__keyevent__:*", ":1")
+	sub.Write([]byte(encode("SET", "a", "INT", "1")))
+	expectLines(t, sr, "-ERR "+errSubscriberMode.Error())
+
+	pub, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer pub.Close()
+	pub.Write([]byte(encode("SET", "a", "INT", "1")))
+	expectLines(t, bufio.NewReader(pub), "+OK")
+
+	expectLines(t, sr, "*4", "$8", "pmessage", "$14", "__keyevent__:*", "$16", "__keyevent__:set", "$1", "a")
+
+	sub.Write([]byte(encode("PUNSUBSCRIBE") + encode("PRINT", "a")))
+	expectLines(t, sr, "*3", "$12", "punsubscribe", "$14", "__keyevent__:*", ":0", "$1", "1")
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+)
+
+// btreeDegree is the minimum number of children of an inner node other than
+// the root; nodes hold between btreeDegree-1 and 2*btreeDegree-1 items.
+const btreeDegree = 32
+
+const (
+	btreeMinItems = btreeDegree - 1
+	btreeMaxItems = 2*btreeDegree - 1
+)
+
+type btreeItem struct {
+	key string
+	val interface{}
+}
+
+type btreeNode struct {
+	items    []btreeItem
+	children []*btreeNode
+}
+
+func (n *btreeNode) leaf() bool {
+	return len(n.children) == 0
+}
+
+// find returns the position of the first item not below key and whether it
+// is key itself.
+func (n *btreeNode) find(key string) (int, bool) {
+	i := sort.Search(len(n.items), func(i int) bool { return n.items[i].key >= key })
+	return i, i < len(n.items) && n.items[i].key == key
+}
+
+// BTreeEngine keeps keys sorted in an in-memory B-tree, so it can scan key
+// ranges with Ascend. Nodes are filled from the top down on insert and
+// rebalanced from the bottom up on delete.
+type BTreeEngine struct {
+	root *btreeNode
+}
+
+func NewBTreeEngine() *BTreeEngine {
+	return &BTreeEngine{root: &btreeNode{}}
+}
+
+func (e *BTreeEngine) Get(key string) (interface{}, bool, error) {
+	n := e.root
+	for {
+		i, found := n.find(key)
+		if found {
+			return n.items[i].val, true, nil
+		}
+		if n.leaf() {
+			return nil, false, nil
+		}
+		n = n.children[i]
+	}
+}
+
+func (e *BTreeEngine) Put(key string, val interface{}) error {
+	if len(e.root.items) == btreeMaxItems {
+		e.root = &btreeNode{children: []*btreeNode{e.root}}
+		e.root.split(0)
+	}
+	e.root.insert(btreeItem{key, val})
+	return nil
+}
+
+// split moves the upper half of the full child i into a new sibling and its
+// middle item up into n, which must have room for it.
+func (n *btreeNode) split(i int) {
+	child := n.children[i]
+	mid := btreeMinItems
+	right := &btreeNode{items: append([]btreeItem(nil), child.items[mid+1:]...)}
+	if !child.leaf() {
+		right.children = append([]*btreeNode(nil), child.children[mid+1:]...)
+		clear(child.children[mid+1:])
+		child.children = child.children[:mid+1]
+	}
+	up := child.items[mid]
+	clear(child.items[mid:])
+	child.items = child.items[:mid]
+
+	n.items = slices.Insert(n.items, i, up)
+	n.children = slices.Insert(n.children, i+1, right)
+}
+
+// insert adds or replaces item below n, which is not full.
+func (n *btreeNode) insert(item btreeItem) {
+	for {
+		i, found := n.find(item.key)
+		if found {
+			n.items[i].val = item.val
+			return
+		}
+		if n.leaf() {
+			n.items = slices.Insert(n.items, i, item)
+			return
+		}
+		if len(n.children[i].items) == btreeMaxItems {
+			n.split(i)
+			switch {
+			case item.key == n.items[i].key:
+				n.items[i].val = item.val
+				return
+			case item.key > n.items[i].key:
+				i++
+			}
+		}
+		n = n.children[i]
+	}
+}
+
+func (e *BTreeEngine) Delete(key string) error {
+	e.root.remove(key)
+	if len(e.root.items) == 0 && !e.root.leaf() {
+		e.root = e.root.children[0]
+	}
+	return nil
+}
+
+// remove deletes key from the subtree of n and reports whether it was there.
+// Children left with too few items are refilled by the caller's fix.
+func (n *btreeNode) remove(key string) bool {
+	i, found := n.find(key)
+	if n.leaf() {
+		if !found {
+			return false
+		}
+		n.items = slices.Delete(n.items, i, i+1)
+		return true
+	}
+	if found {
+		n.items[i] = n.children[i].removeMax()
+	} else if !n.children[i].remove(key) {
+		return false
+	}
+	n.fix(i)
+	return true
+}
+
+func (n *btreeNode) removeMax() btreeItem {
+	if n.leaf() {
+		last := n.items[len(n.items)-1]
+		n.items[len(n.items)-1] = btreeItem{}
+		n.items = n.items[:len(n.items)-1]
+		return last
+	}
+	i := len(n.children) - 1
+	item := n.children[i].removeMax()
+	n.fix(i)
+	return item
+}
+
+// fix refills child i if it has fallen below the minimum, borrowing an item
+// through n from a sibling that can spare one or else merging it with one.
+func (n *btreeNode) fix(i int) {
+	child := n.children[i]
+	if len(child.items) >= btreeMinItems {
+		return
+	}
+	switch {
+	case i > 0 && len(n.children[i-1].items) > btreeMinItems:
+		left := n.children[i-1]
+		child.items = append([]btreeItem{n.items[i-1]}, child.items...)
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items[len(left.items)-1] = btreeItem{}
+		left.items = left.items[:len(left.items)-1]
+		if !left.leaf() {
+			last := len(left.children) - 1
+			child.children = append([]*btreeNode{left.children[last]}, child.children...)
+			left.children[last] = nil
+			left.children = left.children[:last]
+		}
+	case i < len(n.children)-1 && len(n.children[i+1].items) > btreeMinItems:
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = slices.Delete(right.items, 0, 1)
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = slices.Delete(right.children, 0, 1)
+		}
+	case i > 0:
+		n.merge(i - 1)
+	default:
+		n.merge(i)
+	}
+}
+
+// merge joins child i, item i and child i+1 into child i.
+func (n *btreeNode) merge(i int) {
+	left, right := n.children[i], n.children[i+1]
+	left.items = append(left.items, n.items[i])
+	left.items = append(left.items, right.items...)
+	left.children = append(left.children, right.children...)
+
+	n.items = slices.Delete(n.items, i, i+1)
+	n.children = slices.Delete(n.children, i+1, i+2)
+}
+
+func (e *BTreeEngine) Iterate(fn func(key string, val interface{}) bool) error {
+	e.root.ascend("", "", fn)
+	return nil
+}
+
+func (e *BTreeEngine) Ascend(from, to string, fn func(key string, val interface{}) bool) error {
+	e.root.ascend(from, to, fn)
+	return nil
+}
+
+// ascend reports false once fn has asked to stop or to has been reached.
+func (n *btreeNode) ascend(from, to string, fn func(key string, val interface{}) bool) bool {
+	i, _ := n.find(from)
+	for ; i < len(n.items); i++ {
+		if !n.leaf() && !n.children[i].ascend(from, to, fn) {
+			return false
+		}
+		item := n.items[i]
+		if to != "" && item.key >= to {
+			return false
+		}
+		if !fn(item.key, item.val) {
+			return false
+		}
+	}
+	if n.leaf() {
+		return true
+	}
+	return n.children[i].ascend(from, to, fn)
+}
+
+func (e *BTreeEngine) Close() error {
+	return nil
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+const (
+	// PageSize is the unit the disk engine reads, writes and caches.
+	PageSize = 4096
+	// DefaultCachePages is the buffer cache size of each disk engine.
+	DefaultCachePages = 64
+	// compactMinGarbage is how many bytes of dead records a page file may
+	// hold before compaction is considered; past it, compaction runs once the
+	// dead bytes outnumber the live ones.
+	compactMinGarbage = 16 * PageSize
+)
+
+// diskRecord locates the encoded value of a key in the page file.
+type diskRecord struct {
+	off int64
+	n   int
+}
+
+type diskPage struct {
+	no    int64
+	data  [PageSize]byte
+	dirty bool
+}
+
+// DiskEngine keeps values in a page file, encoded as in snapshots, with only
+// the key index in memory. Values are appended and may span pages; replaced
+// and deleted ones leave garbage that compaction copies away. Pages go
+// through an LRU buffer cache that writes dirty pages back when they are
+// evicted.
+//
+// The file is scratch space, created empty and removed on Close:
+// durability is the job of the AOF and snapshots. An I/O error leaves the
+// cache and the file out of step, so the engine returns that error from
+// every later call rather than serve stale data.
+type DiskEngine struct {
+	// mu guards everything, since reads fill and reorder the cache while
+	// the shard is only read-locked.
+	mu     sync.Mutex
+	dir    string
+	file   *os.File
+	failed error
+	index  map[string]diskRecord
+	// end is where the next record goes; size is how far the file itself
+	// reaches, which trails end while the last pages are only in the cache.
+	end     int64
+	size    int64
+	live    int64
+	garbage int64
+
+	cacheSize int
+	pages     map[int64]*list.Element
+	lru       list.List
+}
+
+// NewDiskEngine creates a page file in dir, the system temp directory when
+// empty, cached by cachePages pages.
+func NewDiskEngine(dir string, cachePages int) (*DiskEngine, error) {
+	if cachePages <= 0 {
+		cachePages = DefaultCachePages
+	}
+	f, err := os.CreateTemp(dir, "dbsim-*.pages")
+	if err != nil {
+		return nil, err
+	}
+	return &DiskEngine{
+		dir:       filepath.Dir(f.Name()),
+		file:      f,
+		index:     make(map[string]diskRecord),
+		cacheSize: cachePages,
+		pages:     make(map[int64]*list.Element),
+	}, nil
+}
+
+func (e *DiskEngine) Get(key string) (interface{}, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed != nil {
+		return nil, false, e.failed
+	}
+	rec, ok := e.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := e.load(key, rec)
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+func (e *DiskEngine) Put(key string, val interface{}) error {
+	buf, err := core.AppendValue(nil, val)
+	if err != nil {
+		return fmt.Errorf("disk engine: %s: %w", key, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed != nil {
+		return e.failed
+	}
+	e.drop(key)
+	e.index[key] = diskRecord{e.end, len(buf)}
+	if err := e.write(e.end, buf); err != nil {
+		return err
+	}
+	e.end += int64(len(buf))
+	e.live += int64(len(buf))
+	return e.maybeCompact()
+}
+
+func (e *DiskEngine) Delete(key string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed != nil {
+		return e.failed
+	}
+	e.drop(key)
+	return e.maybeCompact()
+}
+
+// Iterate visits the keys in no particular order.
+func (e *DiskEngine) Iterate(fn func(key string, val interface{}) bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failed != nil {
+		return e.failed
+	}
+	for key, rec := range e.index {
+		val, err := e.load(key, rec)
+		if err != nil {
+			return err
+		}
+		if !fn(key, val) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Close removes the page file.
+func (e *DiskEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return errors.Join(e.file.Close(), os.Remove(e.file.Name()))
+}
+
+// drop forgets the record of key, turning it into garbage.
+func (e *DiskEngine) drop(key string) {
+	if rec, ok := e.index[key]; ok {
+		delete(e.index, key)
+		e.live -= int64(rec.n)
+		e.garbage += int64(rec.n)
+	}
+}
+
+func (e *DiskEngine) load(key string, rec diskRecord) (interface{}, error) {
+	buf := make([]byte, rec.n)
+	if err := e.read(rec.off, buf); err != nil {
+		return nil, err
+	}
+	val, err := core.DecodeValueExact(buf)
+	if err != nil {
+		return nil, e.fail(fmt.Errorf("%s: %w", key, err))
+	}
+	return val, nil
+}
+
+// fail makes err the error of every later call.
+func (e *DiskEngine) fail(err error) error {
+	e.failed = fmt.Errorf("disk engine: %w", err)
+	return e.failed
+}
+
+// read fills buf from the file at off through the cache.
+func (e *DiskEngine) read(off int64, buf []byte) error {
+	for len(buf) > 0 {
+		p, err := e.page(off / PageSize)
+		if err != nil {
+			return err
+		}
+		n := copy(buf, p.data[off%PageSize:])
+		buf, off = buf[n:], off+int64(n)
+	}
+	return nil
+}
+
+// write stores buf at off through the cache; the pages reach the file when
+// they are evicted.
+func (e *DiskEngine) write(off int64, buf []byte) error {
+	for len(buf) > 0 {
+		p, err := e.page(off / PageSize)
+		if err != nil {
+			return err
+		}
+		n := copy(p.data[off%PageSize:], buf)
+		p.dirty = true
+		buf, off = buf[n:], off+int64(n)
+	}
+	return nil
+}
+
+// page returns page no from the cache, reading it in, and evicting the least
+// recently used page to make room, if needed.
+func (e *DiskEngine) page(no int64) (*diskPage, error) {
+	if el, ok := e.pages[no]; ok {
+		e.lru.MoveToFront(el)
+		return el.Value.(*diskPage), nil
+	}
+	if e.lru.Len() >= e.cacheSize {
+		oldest := e.lru.Back()
+		if err := e.flush(oldest.Value.(*diskPage)); err != nil {
+			return nil, err
+		}
+		e.lru.Remove(oldest)
+		delete(e.pages, oldest.Value.(*diskPage).no)
+	}
+
+	p := &diskPage{no: no}
+	if no*PageSize < e.size {
+		if _, err := e.file.ReadAt(p.data[:], no*PageSize); err != nil && err != io.EOF {
+			return nil, e.fail(err)
+		}
+	}
+	e.pages[no] = e.lru.PushFront(p)
+	return p, nil
+}
+
+func (e *DiskEngine) flush(p *diskPage) error {
+	if !p.dirty {
+		return nil
+	}
+	if _, err := e.file.WriteAt(p.data[:], p.no*PageSize); err != nil {
+		return e.fail(err)
+	}
+	p.dirty = false
+	e.size = max(e.size, (p.no+1)*PageSize)
+	return nil
+}
+
+// maybeCompact rewrites the file with only the live records once garbage
+// dominates it. The live records are copied through the cache into a fresh
+// file, which replaces the old one.
+func (e *DiskEngine) maybeCompact() error {
+	if e.garbage < compactMinGarbage || e.garbage < e.live {
+		return nil
+	}
+	f, err := os.CreateTemp(e.dir, "dbsim-*.pages")
+	if err != nil {
+		return e.fail(err)
+	}
+
+	index := make(map[string]diskRecord, len(e.index))
+	var (
+		off int64
+		buf []byte
+	)
+	for key, rec := range e.index {
+		buf = slices.Grow(buf[:0], rec.n)[:rec.n]
+		err := e.read(rec.off, buf)
+		if err == nil {
+			if _, err = f.WriteAt(buf, off); err != nil {
+				err = e.fail(err)
+			}
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+		index[key] = diskRecord{off, rec.n}
+		off += int64(rec.n)
+	}
+
+	old := e.file
+	old.Close()
+	os.Remove(old.Name())
+	e.file, e.index = f, index
+	e.end, e.size, e.live, e.garbage = off, off, off, 0
+	clear(e.pages)
+	e.lru.Init()
+	return nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Engine holds the keys and values of one shard. The TypeBox only uses an
+// engine with the shard's lock held, Put and Delete under the write lock, so
+// engines need no locking of their own unless Get changes internal state.
+// Iterate must not be given a fn that uses the engine. The in-memory engines
+// never fail; the disk engine returns its I/O errors.
+type Engine interface {
+	Get(key string) (interface{}, bool, error)
+	Put(key string, val interface{}) error
+	Delete(key string) error
+	Iterate(fn func(key string, val interface{}) bool) error
+	Close() error
+}
+
+// OrderedEngine is an Engine that keeps its keys sorted. Ascend calls fn for
+// the keys in [from, to) in order; an empty to means no upper bound.
+type OrderedEngine interface {
+	Engine
+	Ascend(from, to string, fn func(key string, val interface{}) bool) error
+}
+
+// EngineFactory creates the engine of shard i.
+type EngineFactory func(i int) (Engine, error)
+
+// EngineNames lists the engines NewEngineFactory knows, the default first.
+var EngineNames = []string{"hash", "btree", "disk"}
+
+// NewEngineFactory returns the factory for the named engine. dir is where the
+// disk engine keeps its page files, the system temp directory when empty.
+func NewEngineFactory(name, dir string) (EngineFactory, error) {
+	switch strings.ToLower(name) {
+	case "hash":
+		return func(int) (Engine, error) { return NewHashEngine(), nil }, nil
+	case "btree":
+		return func(int) (Engine, error) { return NewBTreeEngine(), nil }, nil
+	case "disk":
+		return func(int) (Engine, error) { return NewDiskEngine(dir, DefaultCachePages) }, nil
+	}
+	return nil, fmt.Errorf("unknown storage engine %q", name)
+}
+
+// HashEngine is a plain map, the default engine.
+type HashEngine struct {
+	m map[string]interface{}
+}
+
+func NewHashEngine() *HashEngine {
+	return &HashEngine{m: make(map[string]interface{})}
+}
+
+func (e *HashEngine) Get(key string) (interface{}, bool, error) {
+	val, ok := e.m[key]
+	return val, ok, nil
+}
+
+func (e *HashEngine) Put(key string, val interface{}) error {
+	e.m[key] = val
+	return nil
+}
+
+func (e *HashEngine) Delete(key string) error {
+	delete(e.m, key)
+	return nil
+}
+
+func (e *HashEngine) Iterate(fn func(key string, val interface{}) bool) error {
+	for k, v := range e.m {
+		if !fn(k, v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *HashEngine) Close() error {
+	return nil
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// engines is what the conformance tests run against. The disk engine gets a
+// tiny cache so pages are evicted and read back.
+var engines = []struct {
+	name string
+	new  func(t *testing.T) Engine
+}{
+	{"hash", func(*testing.T) Engine { return NewHashEngine() }},
+	{"btree", func(*testing.T) Engine { return NewBTreeEngine() }},
+	{"disk", func(t *testing.T) Engine {
+		e, err := NewDiskEngine(t.TempDir(), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}},
+}
+
+func forEachEngine(t *testing.T, fn func(t *testing.T, e Engine)) {
+	for _, tc := range engines {
+		t.Run(tc.name, func(t *testing.T) {
+			e := tc.new(t)
+			defer func() {
+				if err := e.Close(); err != nil {
+					t.Error(err)
+				}
+			}()
+			fn(t, e)
+		})
+	}
+}
+
+func collect(e Engine) map[string]interface{} {
+	got := make(map[string]interface{})
+	e.Iterate(func(k string, v interface{}) bool {
+		got[k] = v
+		return true
+	})
+	return got
+}
+
+func TestEngine_GetPutDelete(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, e Engine) {
+		if _, ok, err := e.Get("a"); ok || err != nil {
+			t.Fatalf("empty engine has a: %v, %v", ok, err)
+		}
+		e.Put("a", 1)
+		e.Put("b", "two")
+		e.Put("a", 3)
+		if v, ok, err := e.Get("a"); !ok || v != 3 || err != nil {
+			t.Fatalf("a = %v, %v, %v", v, ok, err)
+		}
+		e.Delete("b")
+		e.Delete("missing")
+		if _, ok, _ := e.Get("b"); ok {
+			t.Fatal("b survived Delete")
+		}
+		if got := collect(e); len(got) != 1 || got["a"] != 3 {
+			t.Fatalf("Iterate saw %v", got)
+		}
+	})
+}
+
+func TestEngine_IterateStops(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, e Engine) {
+		for i := 0; i < 10; i++ {
+			e.Put(strconv.Itoa(i), i)
+		}
+		n := 0
+		e.Iterate(func(string, interface{}) bool {
+			n++
+			return n < 3
+		})
+		if n != 3 {
+			t.Fatalf("Iterate went on for %d keys after being stopped at 3", n)
+		}
+	})
+}
+
+func TestEngine_Values(t *testing.T) {
+	obj := core.NewObjectValue()
+	obj.Data["name"] = "x"
+	obj.Data["tags"] = core.ListValue{Data: []interface{}{1, 2.5, true, core.NullValue{}}}
+	values := []interface{}{
+		42, 1.5, "text", false, core.NullValue{}, []byte{0, 1, 2},
+		time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), obj,
+		core.NewSetValue("b", "a"),
+		core.NewSortedSetValue(core.ZEntry{Member: "m", Score: 2}, core.ZEntry{Member: "n", Score: -1}),
+	}
+	forEachEngine(t, func(t *testing.T, e Engine) {
+		for i, v := range values {
+			e.Put(strconv.Itoa(i), v)
+		}
+		for i, want := range values {
+			got, ok, _ := e.Get(strconv.Itoa(i))
+			if !ok || core.FormatValue(got) != core.FormatValue(want) || core.KindOf(got) != core.KindOf(want) {
+				t.Errorf("value %d: got %v (%s), want %v (%s)", i, got, core.KindOf(got), want, core.KindOf(want))
+			}
+		}
+	})
+}
+
+// TestEngine_RandomOps checks an engine against a map through enough writes
+// to split and merge B-tree nodes and to evict and compact disk pages.
+func TestEngine_RandomOps(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, e Engine) {
+		rng := rand.New(rand.NewPCG(1, 2))
+		model := make(map[string]interface{})
+		for i := 0; i < 60000; i++ {
+			key := fmt.Sprintf("k%05d", rng.IntN(10000))
+			if rng.IntN(3) == 0 {
+				e.Delete(key)
+				delete(model, key)
+			} else {
+				val := fmt.Sprintf("%s-%d-%0*d", key, i, rng.IntN(200), 0)
+				e.Put(key, val)
+				model[key] = val
+			}
+			if i%5000 == 0 {
+				for k, want := range model {
+					if got, ok, _ := e.Get(k); !ok || got != want {
+						t.Fatalf("step %d: %s = %v, %v; want %v", i, k, got, ok, want)
+					}
+				}
+			}
+		}
+		got := collect(e)
+		if len(got) != len(model) {
+			t.Fatalf("Iterate saw %d keys, want %d", len(got), len(model))
+		}
+		for k, want := range model {
+			if got[k] != want {
+				t.Fatalf("%s = %v, want %v", k, got[k], want)
+			}
+		}
+		for k := range model {
+			e.Delete(k)
+		}
+		if got := collect(e); len(got) != 0 {
+			t.Fatalf("%d keys left after deleting all", len(got))
+		}
+	})
+}
+
+// TestDiskEngine_IOError closes the page file under the engine: the write
+// that evicts a page fails, and so does every call after it.
+func TestDiskEngine_IOError(t *testing.T) {
+	e, err := NewDiskEngine(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.file.Close()
+
+	big := strings.Repeat("x", PageSize)
+	if err := e.Put("a", big); err == nil {
+		t.Fatal("Put spanning two pages of a one-page cache wrote to a closed file")
+	}
+	if _, _, err := e.Get("a"); err == nil {
+		t.Fatal("Get after a failed write succeeded")
+	}
+	if err := e.Put("b", 1); err == nil {
+		t.Fatal("Put after a failed write succeeded")
+	}
+}
+
+func TestTypeBox_EngineErrorIsKept(t *testing.T) {
+	tb, err := NewTypeBoxWithEngine(1, func(int) (Engine, error) { return NewDiskEngine(t.TempDir(), 1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	tb.Put("a", 1)
+	if err := tb.Err(); err != nil {
+		t.Fatalf("Err before any failure: %v", err)
+	}
+
+	tb.shards[0].store.(*DiskEngine).file.Close()
+	tb.Put("b", strings.Repeat("x", PageSize))
+	if err := tb.Err(); err == nil {
+		t.Fatal("Err is nil after a failed write")
+	}
+	if _, ok := tb.Get("a"); ok {
+		t.Fatal("Get read through a failed engine")
+	}
+}
+
+func TestBTreeEngine_Ascend(t *testing.T) {
+	e := NewBTreeEngine()
+	var want []string
+	for i := 0; i < 5000; i += 2 {
+		e.Put(fmt.Sprintf("k%05d", i), i)
+	}
+	for i := 1000; i < 2000; i += 2 {
+		want = append(want, fmt.Sprintf("k%05d", i))
+	}
+
+	var got []string
+	e.Ascend("k00999", "k02000", func(k string, _ interface{}) bool {
+		got = append(got, k)
+		return true
+	})
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Ascend returned %d keys from %v to %v, want %d", len(got), got[0], got[len(got)-1], len(want))
+	}
+
+	var all []string
+	e.Iterate(func(k string, _ interface{}) bool {
+		all = append(all, k)
+		return true
+	})
+	if len(all) != 2500 || !sort.StringsAreSorted(all) {
+		t.Fatalf("Iterate returned %d keys, sorted %v", len(all), sort.StringsAreSorted(all))
+	}
+}
+
+func TestTypeBox_Engines(t *testing.T) {
+	for _, name := range EngineNames {
+		t.Run(name, func(t *testing.T) {
+			factory, err := NewEngineFactory(name, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tb, err := NewTypeBoxWithEngine(4, factory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tb.Close()
+
+			for i := 0; i < 50; i++ {
+				tb.Put(fmt.Sprintf("key:%02d", i), i)
+			}
+			tb.Push("list", 1)
+			tb.Push("list", 2)
+			if ok, err := tb.Rename("key:00", "key:99", false); !ok || err != nil {
+				t.Fatalf("Rename: %v, %v", ok, err)
+			}
+			tb.Delete("key:01")
+
+			var keys []string
+			tb.RangeKeys("key:10", "key:20", func(k string, v interface{}) bool {
+				keys = append(keys, k)
+				return true
+			})
+			if len(keys) != 10 || keys[0] != "key:10" || keys[9] != "key:19" || !sort.StringsAreSorted(keys) {
+				t.Fatalf("RangeKeys returned %v", keys)
+			}
+			if v, _ := tb.Get("list"); core.FormatValue(v) != "[1,2]" {
+				t.Fatalf("list = %v", core.FormatValue(v))
+			}
+
+			n := 0
+			tb.Range(func(string, interface{}) bool { n++; return true })
+			if n != 50 {
+				t.Fatalf("Range saw %d keys, want 50", n)
+			}
+			tb.Clear()
+			if _, ok := tb.Get("key:99"); ok {
+				t.Fatal("key:99 survived Clear")
+			}
+		})
+	}
+}
+
+func TestNewEngineFactory_Unknown(t *testing.T) {
+	if _, err := NewEngineFactory("lsm", ""); err == nil {
+		t.Fatal("expected an error for an unknown engine")
+	}
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// EventKind names what happened to a key.
+type EventKind string
+
+const (
+	EventSet   EventKind = "set"
+	EventPush  EventKind = "push"
+	EventMerge EventKind = "merge"
+	EventDel   EventKind = "del"
+	// EventExpire is a deadline being set on a key; EventExpired is the key
+	// being removed when the deadline passes.
+	EventExpire  EventKind = "expire"
+	EventExpired EventKind = "expired"
+)
+
+// Keyspace events are published on two channels, as in Redis: one per key,
+// carrying the event kind, and one per kind, carrying the key.
+const (
+	KeyspacePrefix = "__keyspace__:"
+	KeyeventPrefix = "__keyevent__:"
+)
+
+// DefaultEventBuffer is the number of messages a subscriber may fall behind
+// before further messages are dropped.
+const DefaultEventBuffer = 1024
+
+// Message is one delivery to a subscriber. Pattern is the PSUBSCRIBE pattern
+// that matched, empty for a plain channel subscription.
+type Message struct {
+	Pattern string
+	Channel string
+	Payload string
+}
+
+// Subscription receives the keyspace events on the channels and patterns it
+// subscribes to. Messages are sent without blocking the writer that caused
+// them: once the buffer is full, new messages are dropped and counted.
+type Subscription struct {
+	hub *eventHub
+	ch  chan Message
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	dropped  atomic.Uint64
+	closed   bool
+}
+
+type eventHub struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+	// active counts the subscriptions, so writers skip building channel
+	// names while nobody listens.
+	active atomic.Int32
+
+	// The events of held keys wait in pending until ReleaseEvents.
+	holding atomic.Bool
+	holdMu  sync.Mutex
+	held    map[string]struct{}
+	pending []heldEvent
+}
+
+type heldEvent struct {
+	kind EventKind
+	key  string
+}
+
+// Subscribe creates a subscription with room for buffer pending messages,
+// DefaultEventBuffer when buffer <= 0. It hears nothing until channels or
+// patterns are added; Close releases it.
+func (tb *TypeBox) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	sub := &Subscription{
+		hub:      &tb.events,
+		ch:       make(chan Message, buffer),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	h := &tb.events
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[*Subscription]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	h.active.Add(1)
+	h.mu.Unlock()
+	return sub
+}
+
+// SubscribeFunc is Subscribe with fn called for every message on a goroutine
+// of its own, so a slow fn delays only its own deliveries.
+func (tb *TypeBox) SubscribeFunc(buffer int, fn func(Message)) *Subscription {
+	sub := tb.Subscribe(buffer)
+	go func() {
+		for msg := range sub.ch {
+			fn(msg)
+		}
+	}()
+	return sub
+}
+
+// C delivers the messages. It is closed by Close.
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Dropped counts the messages lost because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Subscribe adds exact channel names and returns the total number of
+// channels and patterns subscribed afterwards.
+func (s *Subscription) Subscribe(channels ...string) int {
+	return s.change(s.channels, channels, true)
+}
+
+// PSubscribe adds glob patterns over channel names.
+func (s *Subscription) PSubscribe(patterns ...string) int {
+	return s.change(s.patterns, patterns, true)
+}
+
+// Unsubscribe removes channels, or all of them when none are given.
+func (s *Subscription) Unsubscribe(channels ...string) int {
+	return s.change(s.channels, channels, false)
+}
+
+// PUnsubscribe removes patterns, or all of them when none are given.
+func (s *Subscription) PUnsubscribe(patterns ...string) int {
+	return s.change(s.patterns, patterns, false)
+}
+
+func (s *Subscription) change(set map[string]struct{}, names []string, add bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case add:
+		for _, name := range names {
+			set[name] = struct{}{}
+		}
+	case len(names) == 0:
+		clear(set)
+	default:
+		for _, name := range names {
+			delete(set, name)
+		}
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// Channels lists the subscribed channels and patterns, sorted.
+func (s *Subscription) Channels() (channels, patterns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.channels {
+		channels = append(channels, name)
+	}
+	for name := range s.patterns {
+		patterns = append(patterns, name)
+	}
+	sort.Strings(channels)
+	sort.Strings(patterns)
+	return channels, patterns
+}
+
+// Close stops delivery and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	h.active.Add(-1)
+	close(s.ch)
+}
+
+func (s *Subscription) send(msg Message) {
+	select {
+	case s.ch <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// deliver sends every message the event produces for s: one per matching
+// channel and one per matching pattern.
+func (s *Subscription) deliver(kind EventKind, key string) {
+	keyspace := KeyspacePrefix + key
+	keyevent := KeyeventPrefix + string(kind)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range []Message{{Channel: keyspace, Payload: string(kind)}, {Channel: keyevent, Payload: key}} {
+		if _, ok := s.channels[m.Channel]; ok {
+			s.send(m)
+		}
+		for pattern := range s.patterns {
+			if core.MatchGlob(pattern, m.Channel) {
+				s.send(Message{Pattern: pattern, Channel: m.Channel, Payload: m.Payload})
+			}
+		}
+	}
+}
+
+// HoldEvents queues the events of keys instead of publishing them, until
+// ReleaseEvents publishes or drops them. Transactions hold the keys they
+// write, so subscribers never hear of writes that are rolled back. Only one
+// hold may be active at a time.
+func (tb *TypeBox) HoldEvents(keys []string) {
+	h := &tb.events
+	h.holdMu.Lock()
+	defer h.holdMu.Unlock()
+	h.held = make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		h.held[key] = struct{}{}
+	}
+	h.holding.Store(true)
+}
+
+// ReleaseEvents ends the hold, publishing the queued events in order if
+// publish is set and dropping them otherwise.
+func (tb *TypeBox) ReleaseEvents(publish bool) {
+	h := &tb.events
+	h.holdMu.Lock()
+	pending := h.pending
+	h.held, h.pending = nil, nil
+	h.holding.Store(false)
+	h.holdMu.Unlock()
+
+	if publish {
+		for _, ev := range pending {
+			h.publish(ev.kind, ev.key)
+		}
+	}
+}
+
+// hold queues the event if key is held.
+func (h *eventHub) hold(kind EventKind, key string) bool {
+	h.holdMu.Lock()
+	defer h.holdMu.Unlock()
+	if _, ok := h.held[key]; !ok {
+		return false
+	}
+	h.pending = append(h.pending, heldEvent{kind, key})
+	return true
+}
+
+// notify publishes an event. Writers call it with shard locks held, which is
+// why delivery never blocks.
+func (tb *TypeBox) notify(kind EventKind, key string) {
+	h := &tb.events
+	if h.active.Load() == 0 {
+		return
+	}
+	if h.holding.Load() && h.hold(kind, key) {
+		return
+	}
+	h.publish(kind, key)
+}
+
+func (h *eventHub) publish(kind EventKind, key string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		sub.deliver(kind, key)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func nextMessage(t *testing.T, sub *Subscription) Message {
+	t.Helper()
+	select {
+	case msg := <-sub.C():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+		return Message{}
+	}
+}
+
+func TestEvents_KindsOnKeyeventChannels(t *testing.T) {
+	tb, clock := newTestBox()
+	sub := tb.Subscribe(0)
+	defer sub.Close()
+	sub.PSubscribe(KeyeventPrefix + "*")
+
+	tb.Put("a", 1)
+	tb.Push("l", 2)
+	tb.SaveObject("o", core.NewObjectValue())
+	tb.SaveObject("p", core.NewObjectValue())
+	tb.MergeObjects("o", "p")
+	tb.Expire("a", time.Second)
+	clock.Advance(time.Second)
+	tb.Get("a")
+	tb.Delete("l")
+
+	want := []Message{
+		{KeyeventPrefix + "*", KeyeventPrefix + "set", "a"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "push", "l"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "set", "o"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "set", "p"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "merge", "o"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "expire", "a"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "expired", "a"},
+		{KeyeventPrefix + "*", KeyeventPrefix + "del", "l"},
+	}
+	for _, w := range want {
+		if got := nextMessage(t, sub); got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestEvents_ChannelSubscriptionsAndUnsubscribe(t *testing.T) {
+	tb := NewTypeBox()
+	sub := tb.Subscribe(0)
+	defer sub.Close()
+	if n := sub.Subscribe(KeyspacePrefix+"a", KeyspacePrefix+"b"); n != 2 {
+		t.Fatalf("count after subscribe: %d", n)
+	}
+
+	tb.Put("a", 1)
+	tb.Put("c", 1)
+	if got := nextMessage(t, sub); got != (Message{Channel: KeyspacePrefix + "a", Payload: "set"}) {
+		t.Fatalf("got %+v", got)
+	}
+
+	if n := sub.Unsubscribe(); n != 0 {
+		t.Fatalf("count after unsubscribe: %d", n)
+	}
+	tb.Put("b", 1)
+	select {
+	case msg := <-sub.C():
+		t.Fatalf("unexpected %+v", msg)
+	default:
+	}
+}
+
+func TestEvents_SlowSubscriberDropsInsteadOfBlocking(t *testing.T) {
+	tb := NewTypeBox()
+	sub := tb.Subscribe(2)
+	sub.PSubscribe(KeyspacePrefix + "*")
+
+	for i := 0; i < 5; i++ {
+		tb.Put("k", i)
+	}
+	if got := sub.Dropped(); got != 3 {
+		t.Fatalf("dropped %d, want 3", got)
+	}
+	sub.Close()
+	sub.Close()
+	for range sub.C() {
+	}
+}
+
+func TestEvents_SubscribeFunc(t *testing.T) {
+	tb := NewTypeBox()
+	got := make(chan Message, 1)
+	sub := tb.SubscribeFunc(0, func(msg Message) { got <- msg })
+	defer sub.Close()
+	sub.Subscribe(KeyeventPrefix + "del")
+
+	tb.Put("k", 1)
+	tb.Delete("k")
+	select {
+	case msg := <-got:
+		if msg.Payload != "k" {
+			t.Fatalf("got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback not called")
+	}
+}
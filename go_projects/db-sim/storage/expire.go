@@ -0,0 +1,122 @@
+package storage
+
+import "time"
+
+type Clock func() time.Time
+
+// SetClock replaces the time source used for key expiration; tests pass a
+// fake clock to make expiry deterministic. Call it before the TypeBox is
+// shared between goroutines.
+func (tb *TypeBox) SetClock(clock Clock) {
+	tb.clock = clock
+}
+
+func (tb *TypeBox) Now() time.Time {
+	return tb.clock()
+}
+
+func (sh *shard) expired(key string, now time.Time) bool {
+	deadline, ok := sh.expires[key]
+	return ok && !now.Before(deadline)
+}
+
+// isExpired only reads the clock for keys that have a deadline.
+func (tb *TypeBox) isExpired(sh *shard, key string) bool {
+	deadline, ok := sh.expires[key]
+	return ok && !tb.clock().Before(deadline)
+}
+
+// expireIfNeeded must be called with the shard write lock held.
+func (tb *TypeBox) expireIfNeeded(sh *shard, key string) bool {
+	if !tb.isExpired(sh, key) {
+		return false
+	}
+	tb.remove(sh, key, EventExpired)
+	return true
+}
+
+// ExpireAt sets an absolute deadline on key. It reports false if the key does
+// not exist. A deadline in the past removes the key right away.
+func (tb *TypeBox) ExpireAt(key string, deadline time.Time) bool {
+	ok := false
+	tb.update(key, func(sh *shard) {
+		if _, exists := tb.get(sh, key); !exists {
+			return
+		}
+		sh.expires[key] = deadline
+		tb.touch(sh, key)
+		if !tb.expireIfNeeded(sh, key) {
+			tb.notify(EventExpire, key)
+		}
+		ok = true
+	})
+	return ok
+}
+
+func (tb *TypeBox) Expire(key string, ttl time.Duration) bool {
+	return tb.ExpireAt(key, tb.clock().Add(ttl))
+}
+
+// Expiry returns the deadline of key, if it has one.
+func (tb *TypeBox) Expiry(key string) (deadline time.Time, ok bool) {
+	tb.view(key, func(sh *shard) {
+		deadline, ok = sh.expires[key]
+	})
+	return deadline, ok
+}
+
+// TTL returns the time left before key expires. exists is false for a missing
+// key and hasTTL is false for a key that never expires.
+func (tb *TypeBox) TTL(key string) (ttl time.Duration, exists, hasTTL bool) {
+	tb.view(key, func(sh *shard) {
+		if _, exists = tb.get(sh, key); !exists {
+			return
+		}
+		var deadline time.Time
+		if deadline, hasTTL = sh.expires[key]; hasTTL {
+			ttl = deadline.Sub(tb.clock())
+		}
+	})
+	return ttl, exists, hasTTL
+}
+
+func (tb *TypeBox) Persist(key string) bool {
+	ok := false
+	tb.update(key, func(sh *shard) {
+		if _, ok = sh.expires[key]; ok {
+			delete(sh.expires, key)
+			tb.touch(sh, key)
+		}
+	})
+	return ok
+}
+
+// SweepExpired is one round of active expiration. In every shard it samples
+// up to sample keys with a deadline, removes the expired ones, and repeats
+// while more than a quarter of the sample turned out to be expired.
+func (tb *TypeBox) SweepExpired(sample int) int {
+	total := 0
+	for _, sh := range tb.shards {
+		sh.mu.Lock()
+		for {
+			now := tb.clock()
+			checked, removed := 0, 0
+			for key := range sh.expires {
+				if checked == sample {
+					break
+				}
+				checked++
+				if sh.expired(key, now) {
+					tb.remove(sh, key, EventExpired)
+					removed++
+				}
+			}
+			total += removed
+			if removed == 0 || removed*4 <= checked {
+				break
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return total
+}
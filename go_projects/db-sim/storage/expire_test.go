@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBox() (*TypeBox, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	tb := NewTypeBox()
+	tb.SetClock(clock.Now)
+	return tb, clock
+}
+
+func TestExpire_LazyOnRead(t *testing.T) {
+	tb, clock := newTestBox()
+	tb.SetScalar("k", "INT", "1")
+
+	if !tb.Expire("k", 10*time.Second) {
+		t.Fatal("expire on existing key should succeed")
+	}
+	if ttl, _, hasTTL := tb.TTL("k"); !hasTTL || ttl != 10*time.Second {
+		t.Fatalf("unexpected ttl %v (hasTTL=%v)", ttl, hasTTL)
+	}
+
+	clock.Advance(9 * time.Second)
+	if _, exists := tb.Get("k"); !exists {
+		t.Fatal("key expired too early")
+	}
+
+	clock.Advance(time.Second)
+	if _, exists := tb.Get("k"); exists {
+		t.Fatal("key should have expired")
+	}
+	if _, exists, _ := tb.TTL("k"); exists {
+		t.Fatal("expired key reported as existing")
+	}
+}
+
+func TestExpire_PersistAndOverwrite(t *testing.T) {
+	tb, clock := newTestBox()
+	tb.SetScalar("a", "INT", "1")
+	tb.SetScalar("b", "INT", "1")
+	tb.Expire("a", time.Second)
+	tb.Expire("b", time.Second)
+
+	if !tb.Persist("a") {
+		t.Fatal("persist should remove the deadline")
+	}
+	tb.SetScalar("b", "INT", "2")
+
+	clock.Advance(time.Hour)
+	if _, exists := tb.Get("a"); !exists {
+		t.Fatal("persisted key expired")
+	}
+	if _, exists := tb.Get("b"); !exists {
+		t.Fatal("SET should clear the previous deadline")
+	}
+	if tb.Expire("missing", time.Second) {
+		t.Fatal("expire on a missing key should fail")
+	}
+}
+
+func TestExpire_ActiveSweep(t *testing.T) {
+	tb, clock := newTestBox()
+	for _, key := range []string{"a", "b", "c"} {
+		tb.SetScalar(key, "INT", "1")
+		tb.Expire(key, time.Second)
+	}
+	tb.SetScalar("keep", "INT", "1")
+
+	if n := tb.SweepExpired(20); n != 0 {
+		t.Fatalf("nothing should expire yet, removed %d", n)
+	}
+	before := tb.Version("a")
+
+	clock.Advance(2 * time.Second)
+	if n := tb.SweepExpired(20); n != 3 {
+		t.Fatalf("expected 3 expired keys, removed %d", n)
+	}
+	if tb.Version("a") == before {
+		t.Fatal("expiry should change the key version")
+	}
+
+	count := 0
+	tb.Range(func(string, interface{}) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("expected 1 key left, got %d", count)
+	}
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+var (
+	ErrIndexExists  = errors.New("index already exists")
+	ErrNoSuchIndex  = errors.New("no such index")
+	errNotIndexable = errors.New("not indexable")
+)
+
+// indexKey is how a field value is filed in an index: numbers by value, so
+// INT 5 and FLOAT 5 share a bucket, everything else by its formatted text.
+type indexKey struct {
+	num   bool
+	value float64
+	text  string
+}
+
+func indexKeyOf(v interface{}) (indexKey, error) {
+	if n, ok := core.Numeric(v); ok {
+		return indexKey{num: true, value: n}, nil
+	}
+	switch v.(type) {
+	case core.ObjectValue, core.ListValue:
+		return indexKey{}, errNotIndexable
+	}
+	return indexKey{text: core.FormatValue(v)}, nil
+}
+
+// index maps the values of one object field to the keys holding them. nums
+// keeps the distinct numeric values sorted for range queries.
+type index struct {
+	name    string
+	field   string
+	path    []core.PathSegment
+	buckets map[indexKey]map[string]struct{}
+	nums    []float64
+}
+
+func (ix *index) add(key string, val interface{}) {
+	fv, ok := core.FieldValue(val, ix.path)
+	if !ok {
+		return
+	}
+	ik, err := indexKeyOf(fv)
+	if err != nil {
+		return
+	}
+	bucket, ok := ix.buckets[ik]
+	if !ok {
+		bucket = make(map[string]struct{})
+		ix.buckets[ik] = bucket
+		if ik.num {
+			i := sort.SearchFloat64s(ix.nums, ik.value)
+			ix.nums = append(ix.nums, 0)
+			copy(ix.nums[i+1:], ix.nums[i:])
+			ix.nums[i] = ik.value
+		}
+	}
+	bucket[key] = struct{}{}
+}
+
+func (ix *index) remove(key string, val interface{}) {
+	fv, ok := core.FieldValue(val, ix.path)
+	if !ok {
+		return
+	}
+	ik, err := indexKeyOf(fv)
+	if err != nil {
+		return
+	}
+	bucket := ix.buckets[ik]
+	delete(bucket, key)
+	if len(bucket) > 0 {
+		return
+	}
+	delete(ix.buckets, ik)
+	if ik.num {
+		i := sort.SearchFloat64s(ix.nums, ik.value)
+		ix.nums = append(ix.nums[:i], ix.nums[i+1:]...)
+	}
+}
+
+// lookup returns the keys the index files under the condition's values, a
+// superset of the keys that match it.
+func (ix *index) lookup(c core.Condition) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	collect := func(ik indexKey) {
+		for key := range ix.buckets[ik] {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	if !c.Ordered() {
+		for i, text := range c.Values {
+			if n, ok := c.Num(i); ok {
+				collect(indexKey{num: true, value: n})
+			}
+			collect(indexKey{text: text})
+		}
+		return keys
+	}
+
+	n, _ := c.Num(0)
+	lo, hi := 0, len(ix.nums)
+	switch c.Op {
+	case core.OpGt:
+		lo = sort.Search(len(ix.nums), func(i int) bool { return ix.nums[i] > n })
+	case core.OpGe:
+		lo = sort.SearchFloat64s(ix.nums, n)
+	case core.OpLt:
+		hi = sort.SearchFloat64s(ix.nums, n)
+	case core.OpLe:
+		hi = sort.Search(len(ix.nums), func(i int) bool { return ix.nums[i] > n })
+	}
+	for _, v := range ix.nums[lo:hi] {
+		collect(indexKey{num: true, value: v})
+	}
+	return keys
+}
+
+// indexSet holds the indexes of a TypeBox. Writers update it while holding
+// a shard lock, so its lock always nests inside shard locks.
+type indexSet struct {
+	mu     sync.RWMutex
+	byName map[string]*index
+	count  atomic.Int32
+}
+
+// replace refiles key after its value changed from old to val; a nil value
+// with had or has false means the key did not or no longer exists.
+func (s *indexSet) replace(key string, old interface{}, had bool, val interface{}, has bool) {
+	if s.count.Load() == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ix := range s.byName {
+		if had {
+			ix.remove(key, old)
+		}
+		if has {
+			ix.add(key, val)
+		}
+	}
+}
+
+func (s *indexSet) reset() {
+	if s.count.Load() == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ix := range s.byName {
+		ix.buckets = make(map[indexKey]map[string]struct{})
+		ix.nums = nil
+	}
+}
+
+// IndexInfo describes an index for listings and AOF rewrites.
+type IndexInfo struct {
+	Name  string
+	Field string
+}
+
+// CreateIndex indexes field, a name or dotted path, across every object in
+// the store. The index is kept current by every later write.
+func (tb *TypeBox) CreateIndex(name, field string) error {
+	path, err := core.ParseFieldPath(field)
+	if err != nil {
+		return err
+	}
+
+	for _, sh := range tb.shards {
+		sh.mu.Lock()
+	}
+	defer func() {
+		for _, sh := range tb.shards {
+			sh.mu.Unlock()
+		}
+	}()
+
+	tb.indexes.mu.Lock()
+	defer tb.indexes.mu.Unlock()
+	if _, exists := tb.indexes.byName[name]; exists {
+		return fmt.Errorf("%w '%s'", ErrIndexExists, name)
+	}
+
+	ix := &index{name: name, field: field, path: path, buckets: make(map[indexKey]map[string]struct{})}
+	now := tb.clock()
+	for _, sh := range tb.shards {
+		tb.each(sh, func(key string, val interface{}) bool {
+			if !sh.expired(key, now) {
+				ix.add(key, val)
+			}
+			return true
+		})
+	}
+	if tb.indexes.byName == nil {
+		tb.indexes.byName = make(map[string]*index)
+	}
+	tb.indexes.byName[name] = ix
+	tb.indexes.count.Add(1)
+	return nil
+}
+
+func (tb *TypeBox) DropIndex(name string) error {
+	tb.indexes.mu.Lock()
+	defer tb.indexes.mu.Unlock()
+	if _, exists := tb.indexes.byName[name]; !exists {
+		return fmt.Errorf("%w '%s'", ErrNoSuchIndex, name)
+	}
+	delete(tb.indexes.byName, name)
+	tb.indexes.count.Add(-1)
+	return nil
+}
+
+// Indexes lists the indexes sorted by name.
+func (tb *TypeBox) Indexes() []IndexInfo {
+	tb.indexes.mu.RLock()
+	defer tb.indexes.mu.RUnlock()
+	infos := make([]IndexInfo, 0, len(tb.indexes.byName))
+	for _, ix := range tb.indexes.byName {
+		infos = append(infos, IndexInfo{Name: ix.name, Field: ix.field})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Plan says how Find answers a condition: through the named index, or by a
+// full scan when Index is empty.
+type Plan struct {
+	Index string
+	Cond  core.Condition
+}
+
+func (p Plan) String() string {
+	switch {
+	case p.Index == "":
+		return fmt.Sprintf("full scan of all keys for %s (no usable index on %s)", p.Cond, p.Cond.Field)
+	case p.Cond.Ordered():
+		return fmt.Sprintf("index range scan on %s for %s", p.Index, p.Cond)
+	default:
+		return fmt.Sprintf("index lookup on %s for %s", p.Index, p.Cond)
+	}
+}
+
+// Explain picks the plan Find would use: the first index, by name, on the
+// condition's field, unless the operator is != which no index can answer.
+func (tb *TypeBox) Explain(c core.Condition) Plan {
+	plan := Plan{Cond: c}
+	if c.Op == core.OpNe {
+		return plan
+	}
+	for _, info := range tb.Indexes() {
+		if info.Field == c.Field {
+			plan.Index = info.Name
+			break
+		}
+	}
+	return plan
+}
+
+// Find returns the sorted keys of the objects matching c and the plan it
+// used. Index candidates are checked against their current value, so the
+// result is the same either way.
+func (tb *TypeBox) Find(c core.Condition) ([]string, Plan) {
+	plan := tb.Explain(c)
+
+	var candidates []string
+	if plan.Index != "" {
+		tb.indexes.mu.RLock()
+		if ix, ok := tb.indexes.byName[plan.Index]; ok {
+			candidates = ix.lookup(c)
+		} else {
+			plan.Index = ""
+		}
+		tb.indexes.mu.RUnlock()
+	}
+
+	var keys []string
+	if plan.Index == "" {
+		tb.Range(func(key string, val interface{}) bool {
+			if c.Match(val) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		sort.Strings(keys)
+		return keys, plan
+	}
+	for _, key := range candidates {
+		if val, exists := tb.Get(key); exists && c.Match(val) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, plan
+}
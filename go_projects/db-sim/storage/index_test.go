@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func user(age int, city string) core.ObjectValue {
+	obj := core.NewObjectValue()
+	obj.Data["age"] = age
+	obj.Data["city"] = city
+	return obj
+}
+
+func find(t *testing.T, tb *TypeBox, cond string) ([]string, Plan) {
+	t.Helper()
+	c, _, err := core.ParseCondition(strings.Fields(cond))
+	if err != nil {
+		t.Fatalf("%s: %v", cond, err)
+	}
+	return tb.Find(c)
+}
+
+func TestIndex_MaintainedByWrites(t *testing.T) {
+	tb, clock := newTestBox()
+	tb.SaveObject("u1", user(20, "Paris"))
+	tb.SaveObject("u2", user(35, "London"))
+	if err := tb.CreateIndex("by_age", "age"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := tb.CreateIndex("by_age", "age"); !errors.Is(err, ErrIndexExists) {
+		t.Fatalf("expected ErrIndexExists, got %v", err)
+	}
+
+	tb.SaveObject("u3", user(41, "Rome"))
+	patch := core.NewObjectValue()
+	patch.Data["age"] = 50.0
+	tb.SaveObject("patch", patch)
+	if err := tb.MergeObjects("u1", "patch"); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	tb.Rename("u2", "u2b", false)
+	tb.SaveObject("u4", user(60, "Oslo"))
+	tb.Expire("u4", time.Second)
+	clock.Advance(2 * time.Second)
+	tb.SaveObject("u5", user(70, "Kyiv"))
+	tb.Delete("u5")
+
+	keys, plan := find(t, tb, "age > 30")
+	if want := []string{"patch", "u1", "u2b", "u3"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got %v, want %v", keys, want)
+	}
+	if plan.Index != "by_age" || !strings.HasPrefix(plan.String(), "index range scan on by_age") {
+		t.Fatalf("unexpected plan %q", plan)
+	}
+	if keys, _ := find(t, tb, "age = 20"); len(keys) != 0 {
+		t.Fatalf("stale entry for u1: %v", keys)
+	}
+
+	tb.Clear()
+	if keys, _ := find(t, tb, "age >= 0"); len(keys) != 0 {
+		t.Fatalf("index not cleared: %v", keys)
+	}
+}
+
+func TestIndex_SameResultAsFullScan(t *testing.T) {
+	tb := NewTypeBox()
+	cities := []string{"Paris", "London", "Rome"}
+	for i := 0; i < 300; i++ {
+		obj := user(i%90, cities[i%3])
+		if i%7 == 0 {
+			obj.Data["age"] = float64(i%90) + 0.5
+		}
+		tb.SaveObject("user:"+strconv.Itoa(i), obj)
+	}
+	tb.Put("scalar", 5)
+
+	conds := []string{"age = 30", "age > 60", "age >= 60", "age < 5", "age <= 4.5", "age IN (1, 2, 7.5)", "city = Rome", "city IN (Paris, Oslo)", "city != Rome"}
+	var scans [][]string
+	for _, cond := range conds {
+		keys, plan := find(t, tb, cond)
+		if plan.Index != "" {
+			t.Fatalf("%s: unexpected index %s", cond, plan.Index)
+		}
+		scans = append(scans, keys)
+	}
+
+	tb.CreateIndex("age", "age")
+	tb.CreateIndex("city", "city")
+	for i, cond := range conds {
+		keys, plan := find(t, tb, cond)
+		if plan.Index == "" && cond != "city != Rome" {
+			t.Fatalf("%s: index not used", cond)
+		}
+		if !reflect.DeepEqual(keys, scans[i]) {
+			t.Errorf("%s: index gave %d keys, scan %d", cond, len(keys), len(scans[i]))
+		}
+	}
+
+	if err := tb.DropIndex("age"); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if _, plan := find(t, tb, "age = 1"); plan.Index != "" {
+		t.Fatalf("dropped index still used")
+	}
+}
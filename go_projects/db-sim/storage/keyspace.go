@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+// Rename moves src, with its expiry, to dst, replacing any value there. With
+// nx set it does nothing and reports false if dst already exists.
+func (tb *TypeBox) Rename(src, dst string, nx bool) (bool, error) {
+	ssh, dsh, unlock := tb.lockPair(src, dst)
+	defer unlock()
+	tb.expireIfNeeded(ssh, src)
+	tb.expireIfNeeded(dsh, dst)
+
+	val, exists := tb.get(ssh, src)
+	if !exists {
+		return false, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, src)
+	}
+	if _, taken := tb.get(dsh, dst); nx && taken {
+		return false, nil
+	}
+	if src == dst {
+		return true, nil
+	}
+
+	deadline, hasTTL := ssh.expires[src]
+	tb.remove(ssh, src, EventDel)
+
+	tb.set(dsh, dst, val, EventSet)
+	delete(dsh.expires, dst)
+	if hasTTL {
+		dsh.expires[dst] = deadline
+	}
+	return true, nil
+}
+
+const scanShardShift = 56
+
+// scanPos orders keys inside a shard by a 56-bit hash, which does not depend
+// on what else is stored, so a cursor stays valid while keys come and go.
+func scanPos(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h >> (64 - scanShardShift)
+}
+
+// Scan returns about count keys starting at cursor and the cursor to pass
+// next; 0 starts and ends an iteration. The cursor holds the shard index in
+// its top byte and a hash position below it. Every key that exists for the
+// whole iteration is returned exactly once, whatever is added or removed in
+// the meantime; keys sharing a hash position are always returned together.
+func (tb *TypeBox) Scan(cursor uint64, count int) ([]string, uint64) {
+	if count <= 0 {
+		count = 10
+	}
+	type entry struct {
+		pos uint64
+		key string
+	}
+
+	var keys []string
+	shardIdx := int(cursor >> scanShardShift)
+	from := cursor & (1<<scanShardShift - 1)
+	for ; shardIdx < len(tb.shards); shardIdx, from = shardIdx+1, 0 {
+		sh := tb.shards[shardIdx]
+		now := tb.clock()
+
+		sh.mu.RLock()
+		var entries []entry
+		tb.each(sh, func(k string, _ interface{}) bool {
+			if pos := scanPos(k); pos >= from && !sh.expired(k, now) {
+				entries = append(entries, entry{pos, k})
+			}
+			return true
+		})
+		sh.mu.RUnlock()
+
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].pos != entries[j].pos {
+				return entries[i].pos < entries[j].pos
+			}
+			return entries[i].key < entries[j].key
+		})
+
+		for i, e := range entries {
+			if len(keys) >= count && i > 0 && e.pos != entries[i-1].pos {
+				return keys, uint64(shardIdx)<<scanShardShift | e.pos
+			}
+			keys = append(keys, e.key)
+		}
+		if len(keys) >= count {
+			next := uint64(shardIdx+1) << scanShardShift
+			if shardIdx+1 == len(tb.shards) {
+				next = 0
+			}
+			return keys, next
+		}
+	}
+	return keys, 0
+}
+
+// RangeKeys calls fn for the live keys in [from, to) in key order; an empty to
+// means no upper bound. Shards with an OrderedEngine are scanned from from;
+// others are read whole and sorted. As in Range, fn runs without any lock.
+func (tb *TypeBox) RangeKeys(from, to string, fn func(key string, val interface{}) bool) {
+	type entry struct {
+		key string
+		val interface{}
+	}
+
+	runs := make([][]entry, len(tb.shards))
+	for i, sh := range tb.shards {
+		now := tb.clock()
+		var run []entry
+		collect := func(k string, v interface{}) bool {
+			if !sh.expired(k, now) {
+				run = append(run, entry{k, v})
+			}
+			return true
+		}
+
+		sh.mu.RLock()
+		if ordered, ok := sh.store.(OrderedEngine); ok {
+			tb.fail(ordered.Ascend(from, to, collect))
+		} else {
+			tb.each(sh, func(k string, v interface{}) bool {
+				if k >= from && (to == "" || k < to) {
+					collect(k, v)
+				}
+				return true
+			})
+			sort.Slice(run, func(a, b int) bool { return run[a].key < run[b].key })
+		}
+		sh.mu.RUnlock()
+		runs[i] = run
+	}
+
+	// Every key lives in one shard, so merging the sorted runs by always
+	// taking the smallest head gives the keys in order.
+	for {
+		next := -1
+		for i, run := range runs {
+			if len(run) > 0 && (next < 0 || run[0].key < runs[next][0].key) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return
+		}
+		e := runs[next][0]
+		runs[next] = runs[next][1:]
+		if !fn(e.key, e.val) {
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func TestScan_StableWhileKeysChange(t *testing.T) {
+	tb := NewShardedTypeBox(8)
+	for i := 0; i < 1000; i++ {
+		tb.Put("stable:"+strconv.Itoa(i), i)
+	}
+	for i := 0; i < 200; i++ {
+		tb.Put("doomed:"+strconv.Itoa(i), i)
+	}
+
+	seen := make(map[string]int)
+	cursor, round := uint64(0), 0
+	for {
+		var keys []string
+		keys, cursor = tb.Scan(cursor, 7)
+		for _, k := range keys {
+			seen[k]++
+		}
+
+		tb.Delete("doomed:" + strconv.Itoa(round%200))
+		tb.Put("added:"+strconv.Itoa(round), round)
+		round++
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		if n := seen["stable:"+strconv.Itoa(i)]; n != 1 {
+			t.Fatalf("stable:%d returned %d times", i, n)
+		}
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Fatalf("%s returned %d times", k, n)
+		}
+	}
+}
+
+func TestRename_KeepsExpiry(t *testing.T) {
+	tb, clock := newTestBox()
+	tb.Put("a", 1)
+	tb.Expire("a", time.Minute)
+	tb.Put("b", 2)
+
+	if ok, _ := tb.Rename("a", "b", true); ok {
+		t.Fatal("RENAMENX onto an existing key should do nothing")
+	}
+	if ok, err := tb.Rename("a", "c", false); !ok || err != nil {
+		t.Fatalf("rename: %v %v", ok, err)
+	}
+	if _, exists := tb.Get("a"); exists {
+		t.Fatal("source still exists")
+	}
+	if ttl, _, hasTTL := tb.TTL("c"); !hasTTL || ttl != time.Minute {
+		t.Fatalf("expiry not moved: %v %v", ttl, hasTTL)
+	}
+
+	clock.Advance(time.Minute)
+	if _, exists := tb.Get("c"); exists {
+		t.Fatal("renamed key should expire")
+	}
+	if _, err := tb.Rename("missing", "x", false); !errors.Is(err, core.ErrNoSuchKey) {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+var ErrOutOfMemory = errors.New("out of memory: used memory is over maxmemory")
+
+// EvictionPolicy decides which keys go when the store is over its memory
+// limit.
+type EvictionPolicy int
+
+const (
+	// NoEviction refuses writes instead of removing anything.
+	NoEviction EvictionPolicy = iota
+	// AllKeysLRU removes the least recently used keys.
+	AllKeysLRU
+	// AllKeysLFU removes the least frequently used keys, the least recently
+	// used first among equals.
+	AllKeysLFU
+	// VolatileTTL removes the keys with an expiry that are closest to it.
+	VolatileTTL
+)
+
+var policyNames = []string{"noeviction", "allkeys-lru", "allkeys-lfu", "volatile-ttl"}
+
+func (p EvictionPolicy) String() string {
+	return policyNames[p]
+}
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	for i, name := range policyNames {
+		if strings.EqualFold(s, name) {
+			return EvictionPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown eviction policy %q", s)
+}
+
+var memoryUnits = []struct {
+	suffix string
+	size   int64
+}{{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}}
+
+// ParseMemorySize reads a byte count such as "64mb", "512kb" or "1048576".
+func ParseMemorySize(s string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(text, u.suffix) {
+			text, unit = strings.TrimSuffix(text, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/unit {
+		return 0, fmt.Errorf("invalid memory size %q", s)
+	}
+	return n * unit, nil
+}
+
+// FormatMemorySize renders n bytes in the largest unit that keeps two
+// decimals meaningful, such as "1.50M".
+func FormatMemorySize(n int64) string {
+	for _, u := range memoryUnits[:3] {
+		if n >= u.size {
+			return strconv.FormatFloat(float64(n)/float64(u.size), 'f', 2, 64) + strings.ToUpper(u.suffix[:1])
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// keyOverhead is charged per key on top of the key and value sizes for the
+// map entries and bookkeeping around it.
+const keyOverhead = 96
+
+// evictionSamples is how many keys are compared to pick each victim. Like
+// Redis, eviction samples instead of keeping every key ordered, so the choice
+// is approximate.
+const evictionSamples = 16
+
+// keyMeta is the accounting kept beside every key. size changes only under
+// the shard write lock; the access fields are updated by readers holding the
+// read lock, hence atomic.
+type keyMeta struct {
+	size       int64
+	lastAccess atomic.Uint64
+	hits       atomic.Uint64
+}
+
+type memory struct {
+	used    atomic.Int64
+	limit   atomic.Int64
+	policy  atomic.Int32
+	evicted atomic.Uint64
+	// ticks is a logical clock for access times, so recency does not depend
+	// on the wall clock's resolution.
+	ticks atomic.Uint64
+}
+
+// SetMaxMemory limits the estimated size of the store to limit bytes, or
+// lifts the limit when limit is 0. The limit is enforced by Reclaim.
+func (tb *TypeBox) SetMaxMemory(limit int64, policy EvictionPolicy) {
+	tb.memory.limit.Store(limit)
+	tb.memory.policy.Store(int32(policy))
+}
+
+// MemoryInfo is what INFO memory reports.
+type MemoryInfo struct {
+	Used    int64
+	Limit   int64
+	Policy  EvictionPolicy
+	Evicted uint64
+}
+
+func (tb *TypeBox) MemoryInfo() MemoryInfo {
+	return MemoryInfo{
+		Used:    tb.memory.used.Load(),
+		Limit:   tb.memory.limit.Load(),
+		Policy:  EvictionPolicy(tb.memory.policy.Load()),
+		Evicted: tb.memory.evicted.Load(),
+	}
+}
+
+// EntrySize is the estimated memory a key holding val takes, as counted
+// against the limit.
+func EntrySize(key string, val interface{}) int64 {
+	return keyOverhead + int64(len(key)) + core.SizeOf(val)
+}
+
+// account records the new size of key with sh write-locked. Writing a key
+// counts as using it.
+func (tb *TypeBox) account(sh *shard, key string, val interface{}) {
+	m := sh.meta[key]
+	if m == nil {
+		m = &keyMeta{}
+		sh.meta[key] = m
+	}
+	size := EntrySize(key, val)
+	tb.memory.used.Add(size - m.size)
+	m.size = size
+	tb.accessed(sh, key)
+}
+
+func (tb *TypeBox) unaccount(sh *shard, key string) {
+	if m := sh.meta[key]; m != nil {
+		tb.memory.used.Add(-m.size)
+		delete(sh.meta, key)
+	}
+}
+
+// accessed needs at least the read lock of sh.
+func (tb *TypeBox) accessed(sh *shard, key string) {
+	if m := sh.meta[key]; m != nil {
+		m.lastAccess.Store(tb.memory.ticks.Add(1))
+		m.hits.Add(1)
+	}
+}
+
+type evictionCandidate struct {
+	key        string
+	lastAccess uint64
+	hits       uint64
+	deadline   time.Time
+}
+
+func (c evictionCandidate) better(o evictionCandidate, policy EvictionPolicy) bool {
+	switch policy {
+	case AllKeysLFU:
+		if c.hits != o.hits {
+			return c.hits < o.hits
+		}
+		return c.lastAccess < o.lastAccess
+	case VolatileTTL:
+		return c.deadline.Before(o.deadline)
+	default:
+		return c.lastAccess < o.lastAccess
+	}
+}
+
+// pickVictim samples keys from shards in a random order and returns the best
+// one to evict under policy. It reports false when there is no candidate.
+func (tb *TypeBox) pickVictim(policy EvictionPolicy) (string, bool) {
+	var (
+		best  evictionCandidate
+		found bool
+		seen  int
+	)
+	perShard := max(evictionSamples/len(tb.shards), 1)
+	start := rand.IntN(len(tb.shards))
+	for i := 0; i < len(tb.shards) && seen < evictionSamples; i++ {
+		sh := tb.shards[(start+i)%len(tb.shards)]
+		sh.mu.RLock()
+		taken := 0
+		consider := func(key string, deadline time.Time) bool {
+			m := sh.meta[key]
+			if m == nil {
+				return true
+			}
+			c := evictionCandidate{key, m.lastAccess.Load(), m.hits.Load(), deadline}
+			if !found || c.better(best, policy) {
+				best, found = c, true
+			}
+			taken++
+			seen++
+			return taken < perShard && seen < evictionSamples
+		}
+		if policy == VolatileTTL {
+			for key, deadline := range sh.expires {
+				if !consider(key, deadline) {
+					break
+				}
+			}
+		} else {
+			for key := range sh.meta {
+				if !consider(key, time.Time{}) {
+					break
+				}
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return best.key, found
+}
+
+// Reclaim evicts keys under the configured policy until the store is back
+// within its memory limit, and returns the evicted keys so the caller can log
+// their removal. It fails with ErrOutOfMemory, having evicted what it could,
+// when the store is over the limit and the policy finds nothing to remove.
+func (tb *TypeBox) Reclaim() ([]string, error) {
+	limit := tb.memory.limit.Load()
+	if limit <= 0 {
+		return nil, nil
+	}
+	policy := EvictionPolicy(tb.memory.policy.Load())
+
+	var evicted []string
+	for tb.memory.used.Load() > limit {
+		if policy == NoEviction {
+			return evicted, ErrOutOfMemory
+		}
+		key, ok := tb.pickVictim(policy)
+		if !ok {
+			return evicted, ErrOutOfMemory
+		}
+		if tb.Delete(key) {
+			tb.memory.evicted.Add(1)
+			evicted = append(evicted, key)
+		}
+	}
+	return evicted, nil
+}
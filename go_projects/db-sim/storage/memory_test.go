@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fill stores n keys of one INT each in a single-shard box, so eviction
+// samples every key and picks exactly.
+func fill(n int) *TypeBox {
+	tb := NewShardedTypeBox(1)
+	for i := 0; i < n; i++ {
+		tb.Put(fmt.Sprintf("k%d", i), i)
+	}
+	return tb
+}
+
+func TestMemory_AccountingFollowsWrites(t *testing.T) {
+	tb := NewTypeBox()
+	tb.Put("a", "x")
+	size := tb.MemoryInfo().Used
+	if size <= 0 {
+		t.Fatalf("used after one key: %d", size)
+	}
+	tb.Put("a", "a much longer string than before")
+	if grown := tb.MemoryInfo().Used; grown <= size {
+		t.Fatalf("used did not grow: %d -> %d", size, grown)
+	}
+	tb.Delete("a")
+	if used := tb.MemoryInfo().Used; used != 0 {
+		t.Fatalf("used after delete: %d", used)
+	}
+	tb.Put("b", 1)
+	tb.Clear()
+	if used := tb.MemoryInfo().Used; used != 0 {
+		t.Fatalf("used after clear: %d", used)
+	}
+}
+
+func TestMemory_NoEvictionRefuses(t *testing.T) {
+	tb := fill(4)
+	tb.SetMaxMemory(1, NoEviction)
+	if _, err := tb.Reclaim(); !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("got %v, want ErrOutOfMemory", err)
+	}
+	if _, exists := tb.Get("k0"); !exists {
+		t.Fatal("noeviction removed a key")
+	}
+}
+
+func TestMemory_Policies(t *testing.T) {
+	cases := []struct {
+		policy EvictionPolicy
+		setup  func(tb *TypeBox)
+		gone   string
+	}{
+		{AllKeysLRU, func(tb *TypeBox) {
+			tb.Get("k0")
+			tb.Get("k2")
+			tb.Get("k3")
+		}, "k1"},
+		{AllKeysLFU, func(tb *TypeBox) {
+			for _, k := range []string{"k0", "k0", "k1", "k1", "k3", "k2"} {
+				tb.Get(k)
+			}
+		}, "k3"},
+		{VolatileTTL, func(tb *TypeBox) {
+			now := time.Now()
+			tb.ExpireAt("k0", now.Add(time.Hour))
+			tb.ExpireAt("k2", now.Add(time.Minute))
+		}, "k2"},
+	}
+	for _, c := range cases {
+		tb := fill(4)
+		c.setup(tb)
+		used := tb.MemoryInfo().Used
+		tb.SetMaxMemory(used-1, c.policy)
+
+		evicted, err := tb.Reclaim()
+		if err != nil {
+			t.Fatalf("%s: %v", c.policy, err)
+		}
+		if len(evicted) != 1 || evicted[0] != c.gone {
+			t.Errorf("%s: evicted %v, want [%s]", c.policy, evicted, c.gone)
+		}
+		if got := tb.MemoryInfo().Evicted; got != 1 {
+			t.Errorf("%s: evicted count %d", c.policy, got)
+		}
+	}
+}
+
+func TestMemory_VolatileTTLNeedsExpiringKeys(t *testing.T) {
+	tb := fill(2)
+	tb.SetMaxMemory(1, VolatileTTL)
+	if _, err := tb.Reclaim(); !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("got %v, want ErrOutOfMemory", err)
+	}
+}
+
+func TestParseMemorySize(t *testing.T) {
+	for in, want := range map[string]int64{"0": 0, "100": 100, "2kb": 2048, "64MB": 64 << 20, "1gb": 1 << 30} {
+		if got, err := ParseMemorySize(in); err != nil || got != want {
+			t.Errorf("%s: got %d, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMemorySize("lots"); err == nil {
+		t.Error("expected an error for 'lots'")
+	}
+}
@@ -1,73 +1,524 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dim4d/DbSim/core"
 )
 
+const (
+	DefaultShards = 32
+	MaxShards     = 256
+)
+
+// TypeBox is safe for concurrent use. Keys are spread over shards by hash and
+// every shard has its own RW lock. Values handed out by Get must be treated
+// as read-only: mutators build new maps instead of editing shared ones.
 type TypeBox struct {
-	store map[string]interface{}
+	shards  []*shard
+	rev     atomic.Uint64
+	clock   Clock
+	indexes indexSet
+	memory  memory
+	events  eventHub
+	// failed is the first error an engine returned.
+	failed atomic.Pointer[error]
+}
+
+type shard struct {
+	mu       sync.RWMutex
+	store    Engine
+	expires  map[string]time.Time
+	versions map[string]uint64
+	// gone is the revision of the last removal in the shard and the version
+	// of every key it does not hold, so versions need no entry for deleted
+	// keys and WATCH still sees them go.
+	gone uint64
+	meta map[string]*keyMeta
 }
 
 func NewTypeBox() *TypeBox {
-	return &TypeBox{
-		store: make(map[string]interface{}),
+	return NewShardedTypeBox(DefaultShards)
+}
+
+func NewShardedTypeBox(n int) *TypeBox {
+	tb, _ := NewTypeBoxWithEngine(n, func(int) (Engine, error) { return NewHashEngine(), nil })
+	return tb
+}
+
+// NewTypeBoxWithEngine creates a TypeBox of n shards, each storing its keys in
+// an engine from newEngine. Close releases the engines.
+func NewTypeBoxWithEngine(n int, newEngine EngineFactory) (*TypeBox, error) {
+	if n <= 0 || n > MaxShards {
+		panic("shard count must be between 1 and 256")
+	}
+	tb := &TypeBox{
+		shards: make([]*shard, n),
+		clock:  time.Now,
+	}
+	for i := range tb.shards {
+		engine, err := newEngine(i)
+		if err != nil {
+			tb.shards = tb.shards[:i]
+			tb.Close()
+			return nil, err
+		}
+		tb.shards[i] = &shard{
+			store:    engine,
+			expires:  make(map[string]time.Time),
+			versions: make(map[string]uint64),
+			meta:     make(map[string]*keyMeta),
+		}
+	}
+	return tb, nil
+}
+
+// Close closes the engines of all shards. The TypeBox must not be used
+// afterwards.
+func (tb *TypeBox) Close() error {
+	var errs []error
+	for _, sh := range tb.shards {
+		sh.mu.Lock()
+		errs = append(errs, sh.store.Close())
+		sh.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Err returns the first error a storage engine returned. After one, the
+// store may have lost writes and should not be trusted.
+func (tb *TypeBox) Err() error {
+	if err := tb.failed.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (tb *TypeBox) fail(err error) {
+	if err != nil {
+		tb.failed.CompareAndSwap(nil, &err)
+	}
+}
+
+// get, put, del and each use the engine of sh, keeping any error it returns
+// for Err. A failed get finds nothing.
+func (tb *TypeBox) get(sh *shard, key string) (interface{}, bool) {
+	val, ok, err := sh.store.Get(key)
+	tb.fail(err)
+	return val, ok
+}
+
+func (tb *TypeBox) put(sh *shard, key string, val interface{}) {
+	tb.fail(sh.store.Put(key, val))
+}
+
+func (tb *TypeBox) del(sh *shard, key string) {
+	tb.fail(sh.store.Delete(key))
+}
+
+func (tb *TypeBox) each(sh *shard, fn func(key string, val interface{}) bool) {
+	tb.fail(sh.store.Iterate(fn))
+}
+
+func (tb *TypeBox) shardIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(tb.shards)))
+}
+
+func (tb *TypeBox) shardFor(key string) *shard {
+	return tb.shards[tb.shardIndex(key)]
+}
+
+// view runs fn under the read lock of key's shard. If key has expired it is
+// removed first, which needs the write lock.
+func (tb *TypeBox) view(key string, fn func(sh *shard)) {
+	sh := tb.shardFor(key)
+	sh.mu.RLock()
+	if !tb.isExpired(sh, key) {
+		fn(sh)
+		sh.mu.RUnlock()
+		return
+	}
+	sh.mu.RUnlock()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	tb.expireIfNeeded(sh, key)
+	fn(sh)
+}
+
+func (tb *TypeBox) update(key string, fn func(sh *shard)) {
+	sh := tb.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	tb.expireIfNeeded(sh, key)
+	fn(sh)
+}
+
+// lockPair write-locks the shards of two keys in index order, so concurrent
+// multi-key operations cannot deadlock.
+func (tb *TypeBox) lockPair(a, b string) (*shard, *shard, func()) {
+	ia, ib := tb.shardIndex(a), tb.shardIndex(b)
+	sa, sb := tb.shards[ia], tb.shards[ib]
+	switch {
+	case ia == ib:
+		sa.mu.Lock()
+		return sa, sb, sa.mu.Unlock
+	case ia < ib:
+		sa.mu.Lock()
+		sb.mu.Lock()
+	default:
+		sb.mu.Lock()
+		sa.mu.Lock()
+	}
+	return sa, sb, func() {
+		sa.mu.Unlock()
+		sb.mu.Unlock()
 	}
 }
 
-func (tb *TypeBox) SetScalar(key, typ, raw string) {
-	val := core.ParsePrimitive(typ, raw)
-	tb.store[key] = val
+func (tb *TypeBox) touch(sh *shard, key string) {
+	sh.versions[key] = tb.rev.Add(1)
+}
+
+// forget drops the version of a removed key, moving it to a new generation.
+func (tb *TypeBox) forget(sh *shard, key string) {
+	delete(sh.versions, key)
+	sh.gone = tb.rev.Add(1)
+}
+
+// set stores val under key with sh write-locked, keeping the indexes in
+// step, and publishes kind. It leaves the expiry alone.
+func (tb *TypeBox) set(sh *shard, key string, val interface{}, kind EventKind) {
+	old, had := tb.get(sh, key)
+	tb.put(sh, key, val)
+	tb.indexes.replace(key, old, had, val, true)
+	tb.account(sh, key, val)
+	tb.touch(sh, key)
+	tb.notify(kind, key)
+}
+
+// remove deletes key and its expiry with sh write-locked.
+func (tb *TypeBox) remove(sh *shard, key string, kind EventKind) {
+	old, had := tb.get(sh, key)
+	tb.del(sh, key)
+	delete(sh.expires, key)
+	tb.indexes.replace(key, old, had, nil, false)
+	tb.unaccount(sh, key)
+	tb.forget(sh, key)
+	tb.notify(kind, key)
+}
+
+// Version changes every time key is written or removed, so comparing two
+// readings tells whether the key was modified in between.
+func (tb *TypeBox) Version(key string) uint64 {
+	var v uint64
+	tb.view(key, func(sh *shard) {
+		var ok bool
+		if v, ok = sh.versions[key]; !ok {
+			v = sh.gone
+		}
+	})
+	return v
+}
+
+func (tb *TypeBox) SetScalar(key, typ, raw string) error {
+	val, err := core.ParsePrimitive(typ, raw)
+	if err != nil {
+		return err
+	}
+	tb.Put(key, val)
+	return nil
 }
 
 func (tb *TypeBox) SaveObject(key string, obj core.ObjectValue) {
-	tb.store[key] = obj
+	tb.Put(key, obj)
 }
 
-func (tb *TypeBox) PushValue(key, typ, raw string) {
-	newVal := core.ParsePrimitive(typ, raw)
-	existingVal, exists := tb.store[key]
+func (tb *TypeBox) PushValue(key, typ, raw string) error {
+	val, err := core.ParsePrimitive(typ, raw)
+	if err != nil {
+		return err
+	}
+	tb.Push(key, val)
+	return nil
+}
 
-	if !exists {
-		tb.store[key] = core.ListValue{Data: []interface{}{newVal}}
-	} else {
-		if listVal, ok := existingVal.(core.ListValue); ok {
-			listVal.Data = append(listVal.Data, newVal)
-			tb.store[key] = listVal
-		} else {
-			newList := core.ListValue{
-				Data: []interface{}{existingVal, newVal},
-			}
-			tb.store[key] = newList
+func (tb *TypeBox) Push(key string, newVal interface{}) {
+	tb.update(key, func(sh *shard) {
+		existingVal, exists := tb.get(sh, key)
+		tb.set(sh, key, core.PushOnto(existingVal, exists, newVal), EventPush)
+	})
+}
+
+// Update replaces the value of key with fn's result in one atomic step. fn
+// sees exists == false for a missing key; if it fails, nothing changes. The
+// key's expiry is kept.
+func (tb *TypeBox) Update(key string, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	return tb.UpdateAs(EventSet, key, fn)
+}
+
+// UpdateAs is Update publishing kind instead of EventSet.
+func (tb *TypeBox) UpdateAs(kind EventKind, key string, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	var err error
+	tb.update(key, func(sh *shard) {
+		old, exists := tb.get(sh, key)
+		var val interface{}
+		if val, err = fn(old, exists); err != nil {
+			return
+		}
+		tb.set(sh, key, val, kind)
+	})
+	return err
+}
+
+// UpdateOrDelete is Update where fn can also remove key by returning
+// keep == false, for values such as sets that vanish once emptied.
+func (tb *TypeBox) UpdateOrDelete(key string, fn func(old interface{}, exists bool) (val interface{}, keep bool, err error)) error {
+	var err error
+	tb.update(key, func(sh *shard) {
+		old, exists := tb.get(sh, key)
+		var (
+			val  interface{}
+			keep bool
+		)
+		if val, keep, err = fn(old, exists); err != nil {
+			return
+		}
+		if keep {
+			tb.set(sh, key, val, EventSet)
+		} else if exists {
+			tb.remove(sh, key, EventDel)
+		}
+	})
+	return err
+}
+
+// UpdatePath is Update for the value at path inside key, which must exist.
+func (tb *TypeBox) UpdatePath(key string, path []core.PathSegment, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	return tb.UpdatePathAs(EventSet, key, path, fn)
+}
+
+// UpdatePathAs is UpdatePath publishing kind instead of EventSet.
+func (tb *TypeBox) UpdatePathAs(kind EventKind, key string, path []core.PathSegment, fn func(old interface{}, exists bool) (interface{}, error)) error {
+	return tb.UpdateAs(kind, key, func(root interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return nil, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, key)
 		}
+		return core.UpdatePath(key, root, path, fn)
+	})
+}
+
+// GetPath reads the value at path inside key.
+func (tb *TypeBox) GetPath(key string, path []core.PathSegment) (interface{}, error) {
+	root, exists := tb.Get(key)
+	if !exists {
+		return nil, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, key)
 	}
+	return core.GetPath(key, root, path)
+}
+
+func (tb *TypeBox) MergeObjects(targetKey, sourceKey string) error {
+	_, err := tb.MergeObjectsWith(targetKey, sourceKey, core.MergeOptions{})
+	return err
 }
 
-func (tb *TypeBox) MergeObjects(targetKey, sourceKey string) {
-	targetRaw, tExists := tb.store[targetKey]
-	sourceRaw, sExists := tb.store[sourceKey]
+// MergeObjectsWith merges the object at sourceKey into the one at targetKey
+// and returns the paths of the fields that conflicted.
+func (tb *TypeBox) MergeObjectsWith(targetKey, sourceKey string, opts core.MergeOptions) ([]string, error) {
+	tsh, ssh, unlock := tb.lockPair(targetKey, sourceKey)
+	defer unlock()
+	tb.expireIfNeeded(tsh, targetKey)
+	tb.expireIfNeeded(ssh, sourceKey)
 
-	if !tExists || !sExists {
-		return
+	targetRaw, tExists := tb.get(tsh, targetKey)
+	sourceRaw, sExists := tb.get(ssh, sourceKey)
+
+	if !tExists {
+		return nil, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, targetKey)
+	}
+	if !sExists {
+		return nil, fmt.Errorf("%w '%s'", core.ErrNoSuchKey, sourceKey)
 	}
 
 	targetObj, tOk := targetRaw.(core.ObjectValue)
+	if !tOk {
+		return nil, fmt.Errorf("%w: '%s' is not an object", core.ErrWrongKind, targetKey)
+	}
 	sourceObj, sOk := sourceRaw.(core.ObjectValue)
+	if !sOk {
+		return nil, fmt.Errorf("%w: '%s' is not an object", core.ErrWrongKind, sourceKey)
+	}
 
-	if tOk && sOk {
-		for k, v := range sourceObj.Data {
-			targetObj.Data[k] = v
-		}
-		tb.store[targetKey] = targetObj
+	merged, conflicts, err := core.Merge(targetObj, sourceObj, opts)
+	if err != nil {
+		return conflicts, err
 	}
+	tb.set(tsh, targetKey, merged, EventMerge)
+	return conflicts, nil
 }
 
 func (tb *TypeBox) PrintKey(key string) {
-	val, exists := tb.store[key]
+	val, exists := tb.Get(key)
 	if !exists {
 		fmt.Println("null")
 		return
 	}
 	fmt.Println(core.FormatValue(val))
 }
+
+func (tb *TypeBox) Get(key string) (interface{}, bool) {
+	var (
+		val    interface{}
+		exists bool
+	)
+	tb.view(key, func(sh *shard) {
+		val, exists = tb.get(sh, key)
+		if exists {
+			tb.accessed(sh, key)
+		}
+	})
+	return val, exists
+}
+
+// Peek is Get without counting as a use of key, for readers such as SAVE
+// that visit every key and should not disturb the eviction order.
+func (tb *TypeBox) Peek(key string) (interface{}, bool) {
+	var (
+		val    interface{}
+		exists bool
+	)
+	tb.view(key, func(sh *shard) {
+		val, exists = tb.get(sh, key)
+	})
+	return val, exists
+}
+
+// Range calls fn for every live key. Each shard is copied under its read
+// lock and fn runs without any lock held, so fn may use the TypeBox.
+func (tb *TypeBox) Range(fn func(key string, val interface{}) bool) {
+	type entry struct {
+		key string
+		val interface{}
+	}
+	for _, sh := range tb.shards {
+		now := tb.clock()
+		sh.mu.RLock()
+		var entries []entry
+		tb.each(sh, func(k string, v interface{}) bool {
+			if !sh.expired(k, now) {
+				entries = append(entries, entry{k, v})
+			}
+			return true
+		})
+		sh.mu.RUnlock()
+
+		for _, e := range entries {
+			if !fn(e.key, e.val) {
+				return
+			}
+		}
+	}
+}
+
+func (tb *TypeBox) Put(key string, val interface{}) {
+	tb.update(key, func(sh *shard) {
+		tb.set(sh, key, val, EventSet)
+		delete(sh.expires, key)
+	})
+}
+
+func (tb *TypeBox) Delete(key string) bool {
+	deleted := false
+	tb.update(key, func(sh *shard) {
+		if _, exists := tb.get(sh, key); !exists {
+			return
+		}
+		tb.remove(sh, key, EventDel)
+		deleted = true
+	})
+	return deleted
+}
+
+// KeyState is a key as Save found it, for Restore to put back.
+type KeyState struct {
+	val      interface{}
+	exists   bool
+	deadline time.Time
+	hasTTL   bool
+	version  uint64
+}
+
+// Save records the value, expiry and version of key.
+func (tb *TypeBox) Save(key string) KeyState {
+	var st KeyState
+	tb.view(key, func(sh *shard) {
+		st.val, st.exists = tb.get(sh, key)
+		st.deadline, st.hasTTL = sh.expires[key]
+		var ok bool
+		if st.version, ok = sh.versions[key]; !ok {
+			st.version = sh.gone
+		}
+	})
+	return st
+}
+
+// Restore puts key back the way Save found it. Undoing a write this way
+// keeps the version too, so WATCH does not take the undo for a change. It
+// publishes no events.
+func (tb *TypeBox) Restore(key string, st KeyState) {
+	tb.update(key, func(sh *shard) {
+		old, had := tb.get(sh, key)
+		switch {
+		case st.exists:
+			tb.put(sh, key, st.val)
+			tb.indexes.replace(key, old, had, st.val, true)
+			tb.account(sh, key, st.val)
+			sh.versions[key] = st.version
+		case had:
+			tb.del(sh, key)
+			tb.indexes.replace(key, old, had, nil, false)
+			tb.unaccount(sh, key)
+			delete(sh.versions, key)
+		}
+		if st.hasTTL {
+			sh.expires[key] = st.deadline
+		} else {
+			delete(sh.expires, key)
+		}
+	})
+}
+
+func (tb *TypeBox) Clear() {
+	for _, sh := range tb.shards {
+		sh.mu.Lock()
+	}
+	for _, sh := range tb.shards {
+		var keys []string
+		tb.each(sh, func(key string, _ interface{}) bool {
+			keys = append(keys, key)
+			return true
+		})
+		for _, key := range keys {
+			tb.del(sh, key)
+			tb.notify(EventDel, key)
+		}
+		sh.versions = make(map[string]uint64)
+		sh.gone = tb.rev.Add(1)
+		sh.expires = make(map[string]time.Time)
+		sh.meta = make(map[string]*keyMeta)
+	}
+	tb.indexes.reset()
+	tb.memory.used.Store(0)
+	for _, sh := range tb.shards {
+		sh.mu.Unlock()
+	}
+}
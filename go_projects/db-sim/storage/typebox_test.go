@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/dim4d/DbSim/core"
+)
+
+func TestTypeBox_ConcurrentPush(t *testing.T) {
+	tb := NewTypeBox()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				tb.Push("list", i)
+				tb.Get("list")
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, _ := tb.Get("list")
+	if n := len(v.(core.ListValue).Data); n != 8*500 {
+		t.Fatalf("expected %d elements, got %d", 8*500, n)
+	}
+}
+
+func TestTypeBox_CrossShardMergeDoesNotDeadlock(t *testing.T) {
+	tb := NewShardedTypeBox(4)
+
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	for _, k := range keys {
+		obj := core.NewObjectValue()
+		obj.Data[k] = 1
+		tb.SaveObject(k, obj)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				a, b := keys[(g+i)%len(keys)], keys[(g+2*i+1)%len(keys)]
+				if err := tb.MergeObjects(a, b); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestTypeBox_MergeDoesNotMutateReadValue(t *testing.T) {
+	tb := NewTypeBox()
+	target := core.NewObjectValue()
+	target.Data["a"] = 1
+	tb.SaveObject("t", target)
+	source := core.NewObjectValue()
+	source.Data["b"] = 2
+	tb.SaveObject("s", source)
+
+	before, _ := tb.Get("t")
+	if err := tb.MergeObjects("t", "s"); err != nil {
+		t.Fatal(err)
+	}
+	if got := core.FormatValue(before); got != "{a:1}" {
+		t.Fatalf("value read before the merge changed: %s", got)
+	}
+}
+
+func benchmarkMixed(b *testing.B, shards int) {
+	tb := NewShardedTypeBox(shards)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+		tb.SetScalar(keys[i], "INT", "0")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				tb.Put(key, i)
+			} else {
+				tb.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+// Run with -cpu 1,2,4,8 to see throughput scale with cores; the single-shard
+// case stands in for a TypeBox behind one global lock.
+func BenchmarkTypeBox_Mixed(b *testing.B) {
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkMixed(b, shards)
+		})
+	}
+}
+
+func TestTypeBox_VersionsOfDeletedKeys(t *testing.T) {
+	tb := NewShardedTypeBox(1)
+	tb.Put("a", 1)
+	set := tb.Version("a")
+	tb.Delete("a")
+	deleted := tb.Version("a")
+	if deleted == set {
+		t.Fatal("Delete kept the version")
+	}
+	if n := len(tb.shards[0].versions); n != 0 {
+		t.Fatalf("%d versions left after deleting the only key", n)
+	}
+	tb.Put("a", 1)
+	if v := tb.Version("a"); v == deleted || v == set {
+		t.Fatal("Put after Delete reused a version")
+	}
+
+	st := tb.Save("a")
+	tb.Put("a", 2)
+	tb.Restore("a", st)
+	if v, _ := tb.Get("a"); v != 1 || tb.Version("a") != st.version {
+		t.Fatalf("Restore left a = %v at version %d, want 1 at %d", v, tb.Version("a"), st.version)
+	}
+}